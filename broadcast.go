@@ -0,0 +1,535 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/spf13/cast"
+)
+
+const (
+	broadcastsDir             = "broadcasts"
+	defaultBroadcastWorkers   = 4
+	maxBroadcastWorkers       = 30
+	broadcastProgressInterval = time.Second
+	broadcastStopTimeout      = 10 * time.Second
+)
+
+// Broadcast job states
+const (
+	broadcastRunning   = "running"
+	broadcastDone      = "done"
+	broadcastCancelled = "cancelled"
+)
+
+// Per-recipient outcomes; an empty outcome means the recipient is pending
+const (
+	outcomeSent        = "sent"
+	outcomeBlocked     = "blocked"
+	outcomeNotFound    = "not_found"
+	outcomeDeactivated = "deactivated"
+	outcomeFailed      = "failed"
+)
+
+// broadcastRecord is the persisted state of a broadcast job
+type broadcastRecord struct {
+	ID         string                 `json:"id"`
+	Status     string                 `json:"status"`
+	Message    map[string]interface{} `json:"message"`
+	Recipients []string               `json:"recipients"`
+	Outcomes   []string               `json:"outcomes"`
+	CreatedAt  int64                  `json:"createdAt"`
+	FinishedAt int64                  `json:"finishedAt,omitempty"`
+}
+
+// broadcastJob sends one message to many chats in the background
+type broadcastJob struct {
+	instance   *BotInstance
+	path       string
+	workers    int
+	onProgress goja.Callable
+
+	mu     sync.Mutex
+	record broadcastRecord
+	next   int
+	dirty  bool
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// createBroadcast creates bot.broadcast(chatIds, message, options?)
+func (instance *BotInstance) createBroadcast() func([]interface{}, map[string]interface{}, map[string]interface{}) (map[string]interface{}, error) {
+	return func(chatIDs []interface{}, message map[string]interface{}, options map[string]interface{}) (map[string]interface{}, error) {
+		if _, err := buildBroadcastSender(message); err != nil {
+			return nil, err
+		}
+
+		id := cast.ToString(options["id"])
+		workers := cast.ToInt(options["concurrency"])
+		onProgress, _ := goja.AssertFunction(instance.runtime.ToValue(options["onProgress"]))
+
+		instance.broadcastsMu.Lock()
+		defer instance.broadcastsMu.Unlock()
+
+		// Broadcasting again with the id of an unfinished job resumes it
+		if job, ok := instance.broadcasts[id]; ok && id != "" {
+			if job.status() == broadcastRunning {
+				job.attach(workers, onProgress)
+				job.start()
+				return job.handle(), nil
+			}
+			return nil, fmt.Errorf("broadcast %q already exists", id)
+		}
+
+		if id == "" {
+			id = newBroadcastID()
+		}
+		recipients := make([]string, len(chatIDs))
+		for i, chatID := range chatIDs {
+			recipients[i] = cast.ToString(chatID)
+			if recipients[i] == "" {
+				return nil, fmt.Errorf("invalid chat ID at index %d", i)
+			}
+		}
+
+		job := &broadcastJob{
+			instance: instance,
+			record: broadcastRecord{
+				ID:         id,
+				Status:     broadcastRunning,
+				Message:    message,
+				Recipients: recipients,
+				Outcomes:   make([]string, len(recipients)),
+				CreatedAt:  time.Now().Unix(),
+			},
+			dirty: true,
+		}
+		if dir := instance.dataPath(broadcastsDir); dir != "" {
+			job.path = filepath.Join(dir, id+".json")
+		}
+		job.attach(workers, onProgress)
+		instance.broadcasts[id] = job
+		job.start()
+		return job.handle(), nil
+	}
+}
+
+// createGetBroadcast returns the handle of a job, or nil if it is unknown
+func (instance *BotInstance) createGetBroadcast() func(string) map[string]interface{} {
+	return func(id string) map[string]interface{} {
+		instance.broadcastsMu.Lock()
+		defer instance.broadcastsMu.Unlock()
+		if job, ok := instance.broadcasts[id]; ok {
+			return job.handle()
+		}
+		return nil
+	}
+}
+
+// restoreBroadcasts loads persisted jobs. Unfinished jobs that were not
+// resumed by the setup callback are started again by resumeBroadcasts.
+func (instance *BotInstance) restoreBroadcasts() {
+	dir := instance.dataPath(broadcastsDir)
+	if dir == "" {
+		return
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return
+	}
+	for _, path := range files {
+		var record broadcastRecord
+		if err := readJSONFile(path, &record); err != nil {
+			fmt.Printf("[ERROR] Failed to restore broadcast: %v\n", err)
+			continue
+		}
+		if record.ID == "" || len(record.Outcomes) != len(record.Recipients) {
+			continue
+		}
+		instance.broadcasts[record.ID] = &broadcastJob{
+			instance: instance,
+			path:     path,
+			workers:  defaultBroadcastWorkers,
+			record:   record,
+		}
+	}
+}
+
+// resumeBroadcasts starts unfinished jobs left over from a previous run
+func (instance *BotInstance) resumeBroadcasts() {
+	instance.broadcastsMu.Lock()
+	defer instance.broadcastsMu.Unlock()
+	for _, job := range instance.broadcasts {
+		if job.status() == broadcastRunning && job.done == nil {
+			job.start()
+		}
+	}
+}
+
+// waitBroadcasts waits until the running jobs of a stopped bot have written
+// their final checkpoint, so a replacing instance restores them from where
+// they stopped instead of re-sending. Gives up after timeout.
+func (instance *BotInstance) waitBroadcasts(timeout time.Duration) {
+	instance.broadcastsMu.Lock()
+	var pending []*broadcastJob
+	for _, job := range instance.broadcasts {
+		job.mu.Lock()
+		if job.done != nil {
+			pending = append(pending, job)
+		}
+		job.mu.Unlock()
+	}
+	instance.broadcastsMu.Unlock()
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	for _, job := range pending {
+		select {
+		case <-job.done:
+		case <-deadline.C:
+			fmt.Printf("[ERROR] Broadcast %s did not stop in time, it may re-send to some recipients\n", job.record.ID)
+			return
+		}
+	}
+}
+
+func newBroadcastID() string {
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// attach sets the options of a new or resumed job
+func (job *broadcastJob) attach(workers int, onProgress goja.Callable) {
+	if workers <= 0 {
+		workers = defaultBroadcastWorkers
+	}
+	if workers > maxBroadcastWorkers {
+		workers = maxBroadcastWorkers
+	}
+	job.mu.Lock()
+	job.workers = workers
+	job.onProgress = onProgress
+	job.mu.Unlock()
+}
+
+// start runs the job in the background unless it is already running
+func (job *broadcastJob) start() {
+	job.mu.Lock()
+	if job.done != nil {
+		job.mu.Unlock()
+		return
+	}
+	ctx, cancel := context.WithCancel(job.instance.ctx)
+	job.cancel = cancel
+	job.done = make(chan struct{})
+	workers := job.workers
+	job.mu.Unlock()
+
+	send, err := buildBroadcastSender(job.record.Message)
+	if err != nil {
+		// Only possible for a corrupted persisted job
+		fmt.Printf("[ERROR] Broadcast %s: %v\n", job.record.ID, err)
+		job.finish(broadcastCancelled)
+		close(job.done)
+		return
+	}
+
+	go func() {
+		defer close(job.done)
+
+		var wg sync.WaitGroup
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				job.work(ctx, send)
+			}()
+		}
+
+		ticker := time.NewTicker(broadcastProgressInterval)
+		defer ticker.Stop()
+		finished := make(chan struct{})
+		go func() {
+			wg.Wait()
+			close(finished)
+		}()
+
+		for {
+			select {
+			case <-ticker.C:
+				job.checkpoint()
+			case <-finished:
+				if job.instance.ctx.Err() != nil {
+					// The bot is stopping: keep the job running so it resumes
+					job.checkpoint()
+					return
+				}
+				if ctx.Err() == nil {
+					job.finish(broadcastDone)
+				}
+				job.checkpoint()
+				return
+			}
+		}
+	}()
+}
+
+// work sends to pending recipients until there are none left
+func (job *broadcastJob) work(ctx context.Context, send broadcastSender) {
+	for ctx.Err() == nil {
+		job.mu.Lock()
+		for job.next < len(job.record.Recipients) && job.record.Outcomes[job.next] != "" {
+			job.next++
+		}
+		if job.next >= len(job.record.Recipients) {
+			job.mu.Unlock()
+			return
+		}
+		index := job.next
+		job.next++
+		chatID := job.record.Recipients[index]
+		job.mu.Unlock()
+
+		err := send(ctx, job.instance.bot, parseChatID(chatID))
+		if err != nil && ctx.Err() != nil {
+			return // Stopped mid-send: leave the recipient pending
+		}
+
+		job.mu.Lock()
+		job.record.Outcomes[index] = broadcastOutcome(err)
+		job.dirty = true
+		job.mu.Unlock()
+	}
+}
+
+// checkpoint persists the job and reports progress to JS
+func (job *broadcastJob) checkpoint() {
+	job.mu.Lock()
+	if !job.dirty {
+		job.mu.Unlock()
+		return
+	}
+	job.dirty = false
+	if job.path != "" {
+		if err := writeJSONFile(job.path, job.record); err != nil {
+			fmt.Printf("[ERROR] Failed to persist broadcast %s: %v\n", job.record.ID, err)
+		}
+	}
+	onProgress := job.onProgress
+	job.mu.Unlock()
+
+	if onProgress == nil || job.instance.ctx.Err() != nil {
+		return
+	}
+	instance := job.instance
+	if instance.waitReady() != nil {
+		return
+	}
+	err := instance.loop.Run(instance.ctx, func() {
+		if _, err := onProgress(goja.Undefined(), instance.runtime.ToValue(job.progress())); err != nil {
+			fmt.Printf("[ERROR] Broadcast progress handler error: %v\n", err)
+		}
+	})
+	if err != nil {
+		fmt.Printf("[ERROR] Broadcast progress dropped: %v\n", err)
+	}
+}
+
+// finish sets the final status of the job
+func (job *broadcastJob) finish(status string) {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	if job.record.Status != broadcastRunning {
+		return
+	}
+	job.record.Status = status
+	job.record.FinishedAt = time.Now().Unix()
+	job.dirty = true
+}
+
+func (job *broadcastJob) status() string {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	return job.record.Status
+}
+
+// progress returns the job summary passed to onProgress and status()
+func (job *broadcastJob) progress() map[string]interface{} {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+
+	counts := make(map[string]int)
+	for _, outcome := range job.record.Outcomes {
+		counts[outcome]++
+	}
+	return map[string]interface{}{
+		"id":          job.record.ID,
+		"status":      job.record.Status,
+		"total":       len(job.record.Recipients),
+		"pending":     counts[""],
+		"sent":        counts[outcomeSent],
+		"blocked":     counts[outcomeBlocked],
+		"notFound":    counts[outcomeNotFound],
+		"deactivated": counts[outcomeDeactivated],
+		"failed":      counts[outcomeFailed],
+	}
+}
+
+// handle creates the job object returned to JS
+func (job *broadcastJob) handle() map[string]interface{} {
+	return map[string]interface{}{
+		"id":     job.record.ID,
+		"status": job.progress,
+		"cancel": func() {
+			job.finish(broadcastCancelled)
+			job.mu.Lock()
+			cancel := job.cancel
+			job.mu.Unlock()
+			if cancel != nil {
+				cancel()
+			} else {
+				job.checkpoint()
+			}
+		},
+		"results": func() []map[string]interface{} {
+			job.mu.Lock()
+			defer job.mu.Unlock()
+			results := make([]map[string]interface{}, len(job.record.Recipients))
+			for i, chatID := range job.record.Recipients {
+				results[i] = map[string]interface{}{
+					"chatId": parseChatID(chatID),
+					"status": job.record.Outcomes[i],
+				}
+			}
+			return results
+		},
+	}
+}
+
+// broadcastSender sends the broadcast message to one chat
+type broadcastSender func(ctx context.Context, b *bot.Bot, chatID interface{}) error
+
+// buildBroadcastSender validates a broadcast message: text, a photo, video,
+// document or animation (file_id or URL) with caption, or a copy of an
+// existing message
+func buildBroadcastSender(message map[string]interface{}) (broadcastSender, error) {
+	parseMode := models.ParseModeHTML
+	if mode, ok := message["parseMode"].(string); ok {
+		parseMode = models.ParseMode(mode)
+	}
+	var markup models.ReplyMarkup
+	if kb := message["inlineKeyboard"]; kb != nil {
+		if keyboard := convertToKeyboardRows(kb); keyboard != nil {
+			markup = buildInlineKeyboard(keyboard)
+		}
+	}
+	silent := cast.ToBool(message["disableNotification"])
+	caption := cast.ToString(message["caption"])
+
+	if source, ok := message["copy"].(map[string]interface{}); ok {
+		fromChatID := parseChatID(cast.ToString(source["chatId"]))
+		messageID := cast.ToInt(source["messageId"])
+		if messageID == 0 {
+			return nil, fmt.Errorf("copy requires chatId and messageId")
+		}
+		return func(ctx context.Context, b *bot.Bot, chatID interface{}) error {
+			_, err := b.CopyMessage(ctx, &bot.CopyMessageParams{
+				ChatID:              chatID,
+				FromChatID:          fromChatID,
+				MessageID:           messageID,
+				DisableNotification: silent,
+				ReplyMarkup:         markup,
+			})
+			return err
+		}, nil
+	}
+
+	if photo := cast.ToString(message["photo"]); photo != "" {
+		return func(ctx context.Context, b *bot.Bot, chatID interface{}) error {
+			_, err := b.SendPhoto(ctx, &bot.SendPhotoParams{
+				ChatID: chatID, Photo: &models.InputFileString{Data: photo},
+				Caption: caption, ParseMode: parseMode, DisableNotification: silent, ReplyMarkup: markup,
+			})
+			return err
+		}, nil
+	}
+	if video := cast.ToString(message["video"]); video != "" {
+		return func(ctx context.Context, b *bot.Bot, chatID interface{}) error {
+			_, err := b.SendVideo(ctx, &bot.SendVideoParams{
+				ChatID: chatID, Video: &models.InputFileString{Data: video},
+				Caption: caption, ParseMode: parseMode, DisableNotification: silent, ReplyMarkup: markup,
+			})
+			return err
+		}, nil
+	}
+	if document := cast.ToString(message["document"]); document != "" {
+		return func(ctx context.Context, b *bot.Bot, chatID interface{}) error {
+			_, err := b.SendDocument(ctx, &bot.SendDocumentParams{
+				ChatID: chatID, Document: &models.InputFileString{Data: document},
+				Caption: caption, ParseMode: parseMode, DisableNotification: silent, ReplyMarkup: markup,
+			})
+			return err
+		}, nil
+	}
+	if animation := cast.ToString(message["animation"]); animation != "" {
+		return func(ctx context.Context, b *bot.Bot, chatID interface{}) error {
+			_, err := b.SendAnimation(ctx, &bot.SendAnimationParams{
+				ChatID: chatID, Animation: &models.InputFileString{Data: animation},
+				Caption: caption, ParseMode: parseMode, DisableNotification: silent, ReplyMarkup: markup,
+			})
+			return err
+		}, nil
+	}
+
+	text := cast.ToString(message["text"])
+	if text == "" {
+		return nil, fmt.Errorf("broadcast message needs text, photo, video, document, animation or copy")
+	}
+	var preview *models.LinkPreviewOptions
+	if cast.ToBool(message["disableWebPagePreview"]) {
+		disabled := true
+		preview = &models.LinkPreviewOptions{IsDisabled: &disabled}
+	}
+	return func(ctx context.Context, b *bot.Bot, chatID interface{}) error {
+		_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: chatID, Text: text, ParseMode: parseMode, LinkPreviewOptions: preview,
+			DisableNotification: silent, ReplyMarkup: markup,
+		})
+		return err
+	}, nil
+}
+
+// broadcastOutcome classifies a send error
+func broadcastOutcome(err error) string {
+	if err == nil {
+		return outcomeSent
+	}
+	switch newTelegramError(err).Kind {
+	case errKindBlocked, errKindKicked, errKindForbidden:
+		return outcomeBlocked
+	case errKindUserDeactivated:
+		return outcomeDeactivated
+	case errKindChatNotFound:
+		return outcomeNotFound
+	}
+	return outcomeFailed
+}
+
+// parseChatID turns a stored chat ID back into a number, leaving @usernames
+// as strings
+func parseChatID(chatID string) interface{} {
+	if id, err := strconv.ParseInt(chatID, 10, 64); err == nil {
+		return id
+	}
+	return chatID
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/dop251/goja"
+)
+
+// panicError is a Go panic recovered while running a handler
+type panicError struct {
+	value interface{}
+	stack string
+}
+
+func (e *panicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.value)
+}
+
+// createCatch registers the bot's error handler: bot.catch((err, ctx) => {})
+func (instance *BotInstance) createCatch() func(goja.Callable) {
+	return func(handler goja.Callable) {
+		instance.errorHandler = handler
+	}
+}
+
+// createCatch registers the error handler of all bots on runtime that have
+// no handler of their own: $telegram.catch((err, ctx) => {})
+func (p *TelegramPlugin) createCatch(runtime *goja.Runtime) func(goja.Callable) {
+	return func(handler goja.Callable) {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.errorHandlers[runtime] = handler
+	}
+}
+
+// handleError passes a failed update to the bot's error handler, or to the
+// runtime-wide one. Without a handler, or when the handler throws itself, the
+// error is logged. Must run on the event loop.
+func (instance *BotInstance) handleError(err error, uctx *UpdateContext, ctxObj map[string]interface{}) {
+	handler := instance.errorHandler
+	if handler == nil {
+		instance.plugin.mu.RLock()
+		handler = instance.plugin.errorHandlers[instance.runtime]
+		instance.plugin.mu.RUnlock()
+	}
+	if handler == nil {
+		instance.logError(uctx.update.ID, err)
+		return
+	}
+
+	if ctxObj == nil {
+		ctxObj = map[string]interface{}{"update": uctx.convertUpdate()}
+	}
+
+	handlerErr := func() (handlerErr error) {
+		defer func() {
+			if r := recover(); r != nil {
+				handlerErr = fmt.Errorf("panic: %v", r)
+			}
+		}()
+		_, handlerErr = handler(goja.Undefined(), instance.errorValue(err), instance.runtime.ToValue(ctxObj))
+		return handlerErr
+	}()
+	if handlerErr != nil {
+		instance.logError(uctx.update.ID, err)
+		instance.logError(uctx.update.ID, fmt.Errorf("error handler failed: %w", handlerErr))
+	}
+}
+
+// errorValue converts a handler failure into the value passed to bot.catch:
+// the thrown value for JS exceptions, an Error named "PanicError" with the Go
+// stack for panics
+func (instance *BotInstance) errorValue(err error) goja.Value {
+	rt := instance.runtime
+
+	var ex *goja.Exception
+	if errors.As(err, &ex) {
+		return ex.Value()
+	}
+
+	obj, jsErr := rt.New(rt.Get("Error"), rt.ToValue(err.Error()))
+	if jsErr != nil {
+		return rt.ToValue(err.Error())
+	}
+	var pe *panicError
+	if errors.As(err, &pe) {
+		obj.Set("name", "PanicError")
+		obj.Set("stack", pe.stack)
+	}
+	return obj
+}
+
+// logError reports an unhandled error with the bot and update it belongs to.
+// It goes through the $logger module M3M registers on the runtime and falls
+// back to stdout when the module is not available. Must run on the event loop.
+func (instance *BotInstance) logError(updateID int64, err error) {
+	name := instance.id
+	if instance.username != "" {
+		name = "@" + instance.username
+	}
+	message := fmt.Sprintf("[telegram] bot %s, update %d: %v", name, updateID, err)
+	var pe *panicError
+	if errors.As(err, &pe) {
+		message += "\n" + pe.stack
+	}
+
+	if logger := instance.runtime.Get("$logger"); logger != nil && !goja.IsUndefined(logger) && !goja.IsNull(logger) {
+		if logFn, ok := goja.AssertFunction(logger.ToObject(instance.runtime).Get("error")); ok {
+			if _, logErr := logFn(logger, instance.runtime.ToValue(message)); logErr == nil {
+				return
+			}
+		}
+	}
+	fmt.Printf("[ERROR] %s\n", message)
+}
@@ -0,0 +1,348 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/spf13/cast"
+)
+
+// convertChatMember converts a chat member with the fields of its status:
+// rights for administrators, permissions for restricted members and
+// untilDate for restricted and banned members
+func (uctx *UpdateContext) convertChatMember(member *models.ChatMember) map[string]interface{} {
+	result := map[string]interface{}{
+		"status": string(member.Type),
+	}
+
+	switch member.Type {
+	case models.ChatMemberTypeOwner:
+		if m := member.Owner; m != nil {
+			result["user"] = uctx.convertUser(m.User)
+			result["isAnonymous"] = m.IsAnonymous
+			result["customTitle"] = m.CustomTitle
+		}
+	case models.ChatMemberTypeAdministrator:
+		if m := member.Administrator; m != nil {
+			result["user"] = uctx.convertUser(&m.User)
+			result["canBeEdited"] = m.CanBeEdited
+			result["isAnonymous"] = m.IsAnonymous
+			result["customTitle"] = m.CustomTitle
+			result["canManageChat"] = m.CanManageChat
+			result["canDeleteMessages"] = m.CanDeleteMessages
+			result["canManageVideoChats"] = m.CanManageVideoChats
+			result["canRestrictMembers"] = m.CanRestrictMembers
+			result["canPromoteMembers"] = m.CanPromoteMembers
+			result["canChangeInfo"] = m.CanChangeInfo
+			result["canInviteUsers"] = m.CanInviteUsers
+			result["canPostMessages"] = m.CanPostMessages
+			result["canEditMessages"] = m.CanEditMessages
+			result["canPinMessages"] = m.CanPinMessages
+			result["canPostStories"] = m.CanPostStories
+			result["canEditStories"] = m.CanEditStories
+			result["canDeleteStories"] = m.CanDeleteStories
+			result["canManageTopics"] = m.CanManageTopics
+		}
+	case models.ChatMemberTypeMember:
+		if m := member.Member; m != nil {
+			result["user"] = uctx.convertUser(m.User)
+			if m.UntilDate != 0 {
+				result["untilDate"] = m.UntilDate
+			}
+		}
+	case models.ChatMemberTypeRestricted:
+		if m := member.Restricted; m != nil {
+			result["user"] = uctx.convertUser(m.User)
+			result["isMember"] = m.IsMember
+			result["untilDate"] = m.UntilDate
+			result["canSendMessages"] = m.CanSendMessages
+			result["canSendAudios"] = m.CanSendAudios
+			result["canSendDocuments"] = m.CanSendDocuments
+			result["canSendPhotos"] = m.CanSendPhotos
+			result["canSendVideos"] = m.CanSendVideos
+			result["canSendVideoNotes"] = m.CanSendVideoNotes
+			result["canSendVoiceNotes"] = m.CanSendVoiceNotes
+			result["canSendPolls"] = m.CanSendPolls
+			result["canSendOtherMessages"] = m.CanSendOtherMessages
+			result["canAddWebPagePreviews"] = m.CanAddWebPagePreviews
+			result["canChangeInfo"] = m.CanChangeInfo
+			result["canInviteUsers"] = m.CanInviteUsers
+			result["canPinMessages"] = m.CanPinMessages
+			result["canManageTopics"] = m.CanManageTopics
+		}
+	case models.ChatMemberTypeLeft:
+		if m := member.Left; m != nil {
+			result["user"] = uctx.convertUser(m.User)
+		}
+	case models.ChatMemberTypeBanned:
+		if m := member.Banned; m != nil {
+			result["user"] = uctx.convertUser(m.User)
+			result["untilDate"] = m.UntilDate
+		}
+	}
+
+	return result
+}
+
+// convertChatMemberUpdated converts a my_chat_member or chat_member update
+func (uctx *UpdateContext) convertChatMemberUpdated(u *models.ChatMemberUpdated) map[string]interface{} {
+	result := map[string]interface{}{
+		"chat":                    uctx.convertChat(u.Chat),
+		"from":                    uctx.convertUser(&u.From),
+		"date":                    u.Date,
+		"oldChatMember":           uctx.convertChatMember(&u.OldChatMember),
+		"newChatMember":           uctx.convertChatMember(&u.NewChatMember),
+		"viaJoinRequest":          u.ViaJoinRequest,
+		"viaChatFolderInviteLink": u.ViaChatFolderInviteLink,
+	}
+	if u.InviteLink != nil {
+		result["inviteLink"] = uctx.convertChatInviteLink(u.InviteLink)
+	}
+	return result
+}
+
+func (uctx *UpdateContext) convertChatJoinRequest(r *models.ChatJoinRequest) map[string]interface{} {
+	result := map[string]interface{}{
+		"chat":       uctx.convertChat(r.Chat),
+		"from":       uctx.convertUser(&r.From),
+		"userChatId": r.UserChatID,
+		"date":       r.Date,
+		"bio":        r.Bio,
+	}
+	if r.InviteLink != nil {
+		result["inviteLink"] = uctx.convertChatInviteLink(r.InviteLink)
+	}
+	return result
+}
+
+func (uctx *UpdateContext) convertChatInviteLink(l *models.ChatInviteLink) map[string]interface{} {
+	return map[string]interface{}{
+		"inviteLink":              l.InviteLink,
+		"creator":                 uctx.convertUser(&l.Creator),
+		"createsJoinRequest":      l.CreatesJoinRequest,
+		"isPrimary":               l.IsPrimary,
+		"isRevoked":               l.IsRevoked,
+		"name":                    l.Name,
+		"expireDate":              l.ExpireDate,
+		"memberLimit":             l.MemberLimit,
+		"pendingJoinRequestCount": l.PendingJoinRequestCount,
+	}
+}
+
+// Join requests
+
+func (instance *BotInstance) createApproveChatJoinRequest() func(int64, int64) error {
+	return func(chatID int64, userID int64) error {
+		_, err := instance.bot.ApproveChatJoinRequest(instance.ctx, &bot.ApproveChatJoinRequestParams{
+			ChatID: chatID,
+			UserID: userID,
+		})
+		return instance.apiError(err)
+	}
+}
+
+func (instance *BotInstance) createDeclineChatJoinRequest() func(int64, int64) error {
+	return func(chatID int64, userID int64) error {
+		_, err := instance.bot.DeclineChatJoinRequest(instance.ctx, &bot.DeclineChatJoinRequestParams{
+			ChatID: chatID,
+			UserID: userID,
+		})
+		return instance.apiError(err)
+	}
+}
+
+// createApproveJoinRequest approves the join request of the current update
+func (uctx *UpdateContext) createApproveJoinRequest() func() error {
+	return func() error {
+		r := uctx.update.ChatJoinRequest
+		if r == nil {
+			return fmt.Errorf("no chat join request in this update")
+		}
+		return uctx.instance.createApproveChatJoinRequest()(r.Chat.ID, r.From.ID)
+	}
+}
+
+// createDeclineJoinRequest declines the join request of the current update
+func (uctx *UpdateContext) createDeclineJoinRequest() func() error {
+	return func() error {
+		r := uctx.update.ChatJoinRequest
+		if r == nil {
+			return fmt.Errorf("no chat join request in this update")
+		}
+		return uctx.instance.createDeclineChatJoinRequest()(r.Chat.ID, r.From.ID)
+	}
+}
+
+// Administration
+
+// parseChatPermissions reads a permissions object with the same keys as a
+// restricted chat member; missing permissions are denied
+func parseChatPermissions(p map[string]interface{}) models.ChatPermissions {
+	return models.ChatPermissions{
+		CanSendMessages:       cast.ToBool(p["canSendMessages"]),
+		CanSendAudios:         cast.ToBool(p["canSendAudios"]),
+		CanSendDocuments:      cast.ToBool(p["canSendDocuments"]),
+		CanSendPhotos:         cast.ToBool(p["canSendPhotos"]),
+		CanSendVideos:         cast.ToBool(p["canSendVideos"]),
+		CanSendVideoNotes:     cast.ToBool(p["canSendVideoNotes"]),
+		CanSendVoiceNotes:     cast.ToBool(p["canSendVoiceNotes"]),
+		CanSendPolls:          cast.ToBool(p["canSendPolls"]),
+		CanSendOtherMessages:  cast.ToBool(p["canSendOtherMessages"]),
+		CanAddWebPagePreviews: cast.ToBool(p["canAddWebPagePreviews"]),
+		CanChangeInfo:         cast.ToBool(p["canChangeInfo"]),
+		CanInviteUsers:        cast.ToBool(p["canInviteUsers"]),
+		CanPinMessages:        cast.ToBool(p["canPinMessages"]),
+		CanManageTopics:       cast.ToBool(p["canManageTopics"]),
+	}
+}
+
+// createBanChatMember creates banChatMember(chatId, userId, { untilDate, revokeMessages })
+func (instance *BotInstance) createBanChatMember() func(int64, int64, map[string]interface{}) error {
+	return func(chatID int64, userID int64, options map[string]interface{}) error {
+		_, err := instance.bot.BanChatMember(instance.ctx, &bot.BanChatMemberParams{
+			ChatID:         chatID,
+			UserID:         userID,
+			UntilDate:      cast.ToInt(options["untilDate"]),
+			RevokeMessages: cast.ToBool(options["revokeMessages"]),
+		})
+		return instance.apiError(err)
+	}
+}
+
+// createUnbanChatMember creates unbanChatMember(chatId, userId, { onlyIfBanned }).
+// Without onlyIfBanned a current member is removed from the chat as well.
+func (instance *BotInstance) createUnbanChatMember() func(int64, int64, map[string]interface{}) error {
+	return func(chatID int64, userID int64, options map[string]interface{}) error {
+		_, err := instance.bot.UnbanChatMember(instance.ctx, &bot.UnbanChatMemberParams{
+			ChatID:       chatID,
+			UserID:       userID,
+			OnlyIfBanned: cast.ToBool(options["onlyIfBanned"]),
+		})
+		return instance.apiError(err)
+	}
+}
+
+// createRestrictChatMember creates restrictChatMember(chatId, userId,
+// permissions, { untilDate, useIndependentChatPermissions })
+func (instance *BotInstance) createRestrictChatMember() func(int64, int64, map[string]interface{}, map[string]interface{}) error {
+	return func(chatID int64, userID int64, permissions map[string]interface{}, options map[string]interface{}) error {
+		perms := parseChatPermissions(permissions)
+		_, err := instance.bot.RestrictChatMember(instance.ctx, &bot.RestrictChatMemberParams{
+			ChatID:                        chatID,
+			UserID:                        userID,
+			Permissions:                   &perms,
+			UseIndependentChatPermissions: cast.ToBool(options["useIndependentChatPermissions"]),
+			UntilDate:                     cast.ToInt(options["untilDate"]),
+		})
+		return instance.apiError(err)
+	}
+}
+
+// createPromoteChatMember creates promoteChatMember(chatId, userId, rights).
+// Rights use the keys of an administrator chat member; passing no rights
+// demotes the user.
+func (instance *BotInstance) createPromoteChatMember() func(int64, int64, map[string]interface{}) error {
+	return func(chatID int64, userID int64, rights map[string]interface{}) error {
+		_, err := instance.bot.PromoteChatMember(instance.ctx, &bot.PromoteChatMemberParams{
+			ChatID:              chatID,
+			UserID:              userID,
+			IsAnonymous:         cast.ToBool(rights["isAnonymous"]),
+			CanManageChat:       cast.ToBool(rights["canManageChat"]),
+			CanDeleteMessages:   cast.ToBool(rights["canDeleteMessages"]),
+			CanManageVideoChats: cast.ToBool(rights["canManageVideoChats"]),
+			CanRestrictMembers:  cast.ToBool(rights["canRestrictMembers"]),
+			CanPromoteMembers:   cast.ToBool(rights["canPromoteMembers"]),
+			CanChangeInfo:       cast.ToBool(rights["canChangeInfo"]),
+			CanInviteUsers:      cast.ToBool(rights["canInviteUsers"]),
+			CanPostMessages:     cast.ToBool(rights["canPostMessages"]),
+			CanEditMessages:     cast.ToBool(rights["canEditMessages"]),
+			CanPinMessages:      cast.ToBool(rights["canPinMessages"]),
+			CanPostStories:      cast.ToBool(rights["canPostStories"]),
+			CanEditStories:      cast.ToBool(rights["canEditStories"]),
+			CanDeleteStories:    cast.ToBool(rights["canDeleteStories"]),
+			CanManageTopics:     cast.ToBool(rights["canManageTopics"]),
+		})
+		return instance.apiError(err)
+	}
+}
+
+func (instance *BotInstance) createSetChatAdministratorCustomTitle() func(int64, int64, string) error {
+	return func(chatID int64, userID int64, title string) error {
+		_, err := instance.bot.SetChatAdministratorCustomTitle(instance.ctx, &bot.SetChatAdministratorCustomTitleParams{
+			ChatID:      chatID,
+			UserID:      userID,
+			CustomTitle: title,
+		})
+		return instance.apiError(err)
+	}
+}
+
+// createBanChatSenderChat bans a channel from posting in the chat on its behalf
+func (instance *BotInstance) createBanChatSenderChat() func(int64, int64) error {
+	return func(chatID int64, senderChatID int64) error {
+		_, err := instance.bot.BanChatSenderChat(instance.ctx, &bot.BanChatSenderChatParams{
+			ChatID:       chatID,
+			SenderChatID: int(senderChatID),
+		})
+		return instance.apiError(err)
+	}
+}
+
+func (instance *BotInstance) createUnbanChatSenderChat() func(int64, int64) error {
+	return func(chatID int64, senderChatID int64) error {
+		_, err := instance.bot.UnbanChatSenderChat(instance.ctx, &bot.UnbanChatSenderChatParams{
+			ChatID:       chatID,
+			SenderChatID: int(senderChatID),
+		})
+		return instance.apiError(err)
+	}
+}
+
+// createSetChatPermissions creates setChatPermissions(chatId, permissions,
+// { useIndependentChatPermissions }), the defaults for all members
+func (instance *BotInstance) createSetChatPermissions() func(int64, map[string]interface{}, map[string]interface{}) error {
+	return func(chatID int64, permissions map[string]interface{}, options map[string]interface{}) error {
+		_, err := instance.bot.SetChatPermissions(instance.ctx, &bot.SetChatPermissionsParams{
+			ChatID:                        chatID,
+			Permissions:                   parseChatPermissions(permissions),
+			UseIndependentChatPermissions: cast.ToBool(options["useIndependentChatPermissions"]),
+		})
+		return instance.apiError(err)
+	}
+}
+
+func (instance *BotInstance) createGetChatAdministrators() func(int64) ([]map[string]interface{}, error) {
+	return func(chatID int64) ([]map[string]interface{}, error) {
+		admins, err := instance.bot.GetChatAdministrators(instance.ctx, &bot.GetChatAdministratorsParams{
+			ChatID: chatID,
+		})
+		if err != nil {
+			return nil, instance.apiError(err)
+		}
+		uctx := &UpdateContext{instance: instance}
+		result := make([]map[string]interface{}, len(admins))
+		for i := range admins {
+			result[i] = uctx.convertChatMember(&admins[i])
+		}
+		return result, nil
+	}
+}
+
+func (instance *BotInstance) createGetChatMemberCount() func(int64) (int, error) {
+	return func(chatID int64) (int, error) {
+		count, err := instance.bot.GetChatMemberCount(instance.ctx, &bot.GetChatMemberCountParams{
+			ChatID: chatID,
+		})
+		return count, instance.apiError(err)
+	}
+}
+
+func (instance *BotInstance) createLeaveChat() func(int64) error {
+	return func(chatID int64) error {
+		_, err := instance.bot.LeaveChat(instance.ctx, &bot.LeaveChatParams{
+			ChatID: chatID,
+		})
+		return instance.apiError(err)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// commandInfo is a parsed "/command@bot payload" message
+type commandInfo struct {
+	name    string
+	mention string
+	payload string
+	args    []string
+}
+
+// parseCommand parses a bot command at the start of text. Returns nil when the
+// text is not a command.
+func parseCommand(text string) *commandInfo {
+	if !strings.HasPrefix(text, "/") || len(text) < 2 {
+		return nil
+	}
+
+	head, payload := text, ""
+	if i := strings.IndexFunc(text, unicode.IsSpace); i >= 0 {
+		head, payload = text[:i], strings.TrimSpace(text[i:])
+	}
+
+	name, mention, _ := strings.Cut(head[1:], "@")
+	if name == "" {
+		return nil
+	}
+
+	return &commandInfo{
+		name:    name,
+		mention: mention,
+		payload: payload,
+		args:    splitArgs(payload),
+	}
+}
+
+// addressedTo reports whether the command may be handled by the bot with the
+// given username. Commands without a mention are addressed to every bot.
+func (cmd *commandInfo) addressedTo(username string) bool {
+	return cmd.mention == "" || username == "" || strings.EqualFold(cmd.mention, username)
+}
+
+// splitArgs splits s into arguments the way a shell does: whitespace
+// separates arguments, single and double quotes group them and a backslash
+// escapes the next character (except inside single quotes)
+func splitArgs(s string) []string {
+	args := []string{}
+	var current strings.Builder
+	inArg := false
+	var quote rune
+	escaped := false
+
+	for _, r := range s {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inArg = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote = r
+			inArg = true
+		case unicode.IsSpace(r):
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+	if escaped {
+		current.WriteRune('\\')
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args
+}
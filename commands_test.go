@@ -0,0 +1,78 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCommand(t *testing.T) {
+	tests := []struct {
+		text    string
+		name    string
+		mention string
+		payload string
+		args    []string
+	}{
+		{"/start", "start", "", "", []string{}},
+		{"/start ref_42", "start", "", "ref_42", []string{"ref_42"}},
+		{"/start\tref_42", "start", "", "ref_42", []string{"ref_42"}},
+		{"/ban@MyBot 12345 spam  bot", "ban", "MyBot", "12345 spam  bot", []string{"12345", "spam", "bot"}},
+		{"/ban\n12345", "ban", "", "12345", []string{"12345"}},
+	}
+	for _, tt := range tests {
+		cmd := parseCommand(tt.text)
+		if cmd == nil {
+			t.Errorf("%q: not parsed as a command", tt.text)
+			continue
+		}
+		if cmd.name != tt.name || cmd.mention != tt.mention || cmd.payload != tt.payload || !reflect.DeepEqual(cmd.args, tt.args) {
+			t.Errorf("%q: got %+v", tt.text, *cmd)
+		}
+	}
+
+	for _, text := range []string{"", "/", "hello", " /start", "/@bot"} {
+		if cmd := parseCommand(text); cmd != nil {
+			t.Errorf("%q: parsed as command %+v", text, *cmd)
+		}
+	}
+}
+
+func TestCommandAddressedTo(t *testing.T) {
+	tests := []struct {
+		text     string
+		username string
+		want     bool
+	}{
+		{"/start", "mybot", true},
+		{"/start@mybot", "MyBot", true},
+		{"/start@otherbot", "mybot", false},
+		{"/start@otherbot", "", true}, // username unknown
+	}
+	for _, tt := range tests {
+		if got := parseCommand(tt.text).addressedTo(tt.username); got != tt.want {
+			t.Errorf("%q to %q: got %v, want %v", tt.text, tt.username, got, tt.want)
+		}
+	}
+}
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{"", []string{}},
+		{"  a  b\tc\n", []string{"a", "b", "c"}},
+		{`"hello world" x`, []string{"hello world", "x"}},
+		{`'it''s' "a \"b\""`, []string{"its", `a "b"`}},
+		{`'no \escape'`, []string{`no \escape`}},
+		{`a\ b c`, []string{"a b", "c"}},
+		{`""`, []string{""}},
+		{`"unterminated quote`, []string{"unterminated quote"}},
+		{`trailing\`, []string{`trailing\`}},
+	}
+	for _, tt := range tests {
+		if got := splitArgs(tt.input); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitArgs(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
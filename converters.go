@@ -14,6 +14,15 @@ func (uctx *UpdateContext) convertUpdate() map[string]interface{} {
 	if u.Message != nil {
 		result["message"] = uctx.convertMessage(u.Message)
 	}
+	if u.EditedMessage != nil {
+		result["editedMessage"] = uctx.convertMessage(u.EditedMessage)
+	}
+	if u.ChannelPost != nil {
+		result["channelPost"] = uctx.convertMessage(u.ChannelPost)
+	}
+	if u.EditedChannelPost != nil {
+		result["editedChannelPost"] = uctx.convertMessage(u.EditedChannelPost)
+	}
 	if u.CallbackQuery != nil {
 		result["callbackQuery"] = map[string]interface{}{
 			"id":           u.CallbackQuery.ID,
@@ -21,14 +30,148 @@ func (uctx *UpdateContext) convertUpdate() map[string]interface{} {
 			"data":         u.CallbackQuery.Data,
 			"chatInstance": u.CallbackQuery.ChatInstance,
 		}
-		if u.CallbackQuery.Message.Message != nil {
-			result["callbackQuery"].(map[string]interface{})["message"] = uctx.convertMessage(u.CallbackQuery.Message.Message)
+		if message := uctx.convertMaybeInaccessibleMessage(&u.CallbackQuery.Message); message != nil {
+			result["callbackQuery"].(map[string]interface{})["message"] = message
+		}
+	}
+
+	if u.InlineQuery != nil {
+		query := map[string]interface{}{
+			"id":       u.InlineQuery.ID,
+			"from":     uctx.convertUser(u.InlineQuery.From),
+			"query":    u.InlineQuery.Query,
+			"offset":   u.InlineQuery.Offset,
+			"chatType": u.InlineQuery.ChatType,
+		}
+		if u.InlineQuery.Location != nil {
+			query["location"] = convertLocation(u.InlineQuery.Location)
+		}
+		result["inlineQuery"] = query
+	}
+	if u.ChosenInlineResult != nil {
+		chosen := map[string]interface{}{
+			"resultId":        u.ChosenInlineResult.ResultID,
+			"from":            uctx.convertUser(&u.ChosenInlineResult.From),
+			"query":           u.ChosenInlineResult.Query,
+			"inlineMessageId": u.ChosenInlineResult.InlineMessageID,
+		}
+		if u.ChosenInlineResult.Location != nil {
+			chosen["location"] = convertLocation(u.ChosenInlineResult.Location)
+		}
+		result["chosenInlineResult"] = chosen
+	}
+
+	if u.ShippingQuery != nil {
+		result["shippingQuery"] = uctx.convertShippingQuery(u.ShippingQuery)
+	}
+	if u.PreCheckoutQuery != nil {
+		result["preCheckoutQuery"] = uctx.convertPreCheckoutQuery(u.PreCheckoutQuery)
+	}
+
+	if u.BusinessConnection != nil {
+		c := u.BusinessConnection
+		result["businessConnection"] = map[string]interface{}{
+			"id":         c.ID,
+			"user":       uctx.convertUser(&c.User),
+			"userChatId": c.UserChatID,
+			"date":       c.Date,
+			"canReply":   c.CanReply,
+			"isEnabled":  c.IsEnabled,
+		}
+	}
+	if u.BusinessMessage != nil {
+		result["businessMessage"] = uctx.convertMessage(u.BusinessMessage)
+	}
+	if u.EditedBusinessMessage != nil {
+		result["editedBusinessMessage"] = uctx.convertMessage(u.EditedBusinessMessage)
+	}
+	if u.DeletedBusinessMessages != nil {
+		d := u.DeletedBusinessMessages
+		result["deletedBusinessMessages"] = map[string]interface{}{
+			"businessConnectionId": d.BusinessConnectionID,
+			"chat":                 uctx.convertChat(d.Chat),
+			"messageIds":           d.MessageIDs,
+		}
+	}
+	if u.MessageReaction != nil {
+		result["messageReaction"] = uctx.convertMessageReaction(u.MessageReaction)
+	}
+	if u.MessageReactionCount != nil {
+		r := u.MessageReactionCount
+		reactions := make([]map[string]interface{}, len(r.Reactions))
+		for i, c := range r.Reactions {
+			reactions[i] = map[string]interface{}{
+				"type":       convertReactionType(&c.Type),
+				"totalCount": c.TotalCount,
+			}
+		}
+		result["messageReactionCount"] = map[string]interface{}{
+			"chat":      uctx.convertChat(r.Chat),
+			"messageId": r.MessageID,
+			"date":      r.Date,
+			"reactions": reactions,
+		}
+	}
+	if u.PurchasedPaidMedia != nil {
+		result["purchasedPaidMedia"] = map[string]interface{}{
+			"from":             uctx.convertUser(&u.PurchasedPaidMedia.From),
+			"paidMediaPayload": u.PurchasedPaidMedia.PaidMediaPayload,
+		}
+	}
+	if u.Poll != nil {
+		result["poll"] = uctx.convertPoll(u.Poll)
+	}
+	if u.PollAnswer != nil {
+		a := u.PollAnswer
+		answer := map[string]interface{}{
+			"pollId":    a.PollID,
+			"optionIds": a.OptionIDs,
+		}
+		if a.VoterChat != nil {
+			answer["voterChat"] = uctx.convertChat(*a.VoterChat)
+		}
+		if a.User != nil {
+			answer["user"] = uctx.convertUser(a.User)
+		}
+		result["pollAnswer"] = answer
+	}
+
+	if u.MyChatMember != nil {
+		result["myChatMember"] = uctx.convertChatMemberUpdated(u.MyChatMember)
+	}
+	if u.ChatMember != nil {
+		result["chatMember"] = uctx.convertChatMemberUpdated(u.ChatMember)
+	}
+	if u.ChatJoinRequest != nil {
+		result["chatJoinRequest"] = uctx.convertChatJoinRequest(u.ChatJoinRequest)
+	}
+	if u.ChatBoost != nil {
+		b := u.ChatBoost.Boost
+		result["chatBoost"] = map[string]interface{}{
+			"chat": uctx.convertChat(u.ChatBoost.Chat),
+			"boost": map[string]interface{}{
+				"boostId":        b.BoostID,
+				"addDate":        b.AddDate,
+				"expirationDate": b.ExpirationDate,
+				"source":         uctx.convertChatBoostSource(&b.Source),
+			},
+		}
+	}
+	if u.RemovedChatBoost != nil {
+		r := u.RemovedChatBoost
+		result["removedChatBoost"] = map[string]interface{}{
+			"chat":       uctx.convertChat(r.Chat),
+			"boostId":    r.BoostID,
+			"removeDate": r.RemoveDate,
+			"source":     uctx.convertChatBoostSource(&r.Source),
 		}
 	}
 
 	return result
 }
 
+// convertMessage converts every field of a message. Text, caption, date and
+// chat are always present; other fields only when the message has them.
 func (uctx *UpdateContext) convertMessage(m *models.Message) map[string]interface{} {
 	msg := map[string]interface{}{
 		"messageId": m.ID,
@@ -37,90 +180,517 @@ func (uctx *UpdateContext) convertMessage(m *models.Message) map[string]interfac
 		"caption":   m.Caption,
 		"chat":      uctx.convertChat(m.Chat),
 	}
+
+	// Sender and context
+	if m.MessageThreadID != 0 {
+		msg["messageThreadId"] = m.MessageThreadID
+	}
 	if m.From != nil {
 		msg["from"] = uctx.convertUser(m.From)
 	}
-	if m.Photo != nil && len(m.Photo) > 0 {
-		photos := make([]map[string]interface{}, len(m.Photo))
-		for i, p := range m.Photo {
-			photos[i] = map[string]interface{}{
-				"fileId":       p.FileID,
-				"fileUniqueId": p.FileUniqueID,
-				"width":        p.Width,
-				"height":       p.Height,
-				"fileSize":     p.FileSize,
-			}
+	if m.SenderChat != nil {
+		msg["senderChat"] = uctx.convertChat(*m.SenderChat)
+	}
+	if m.SenderBoostCount != 0 {
+		msg["senderBoostCount"] = m.SenderBoostCount
+	}
+	if m.SenderBusinessBot != nil {
+		msg["senderBusinessBot"] = uctx.convertUser(m.SenderBusinessBot)
+	}
+	if m.BusinessConnectionID != "" {
+		msg["businessConnectionId"] = m.BusinessConnectionID
+	}
+	if m.ForwardOrigin != nil {
+		msg["forwardOrigin"] = uctx.convertMessageOrigin(m.ForwardOrigin)
+	}
+	if m.IsTopicMessage {
+		msg["isTopicMessage"] = true
+	}
+	if m.IsAutomaticForward {
+		msg["isAutomaticForward"] = true
+	}
+	if m.ReplyToMessage != nil {
+		msg["replyToMessage"] = uctx.convertMessage(m.ReplyToMessage)
+	}
+	if m.ExternalReply != nil {
+		msg["externalReply"] = uctx.convertExternalReply(m.ExternalReply)
+	}
+	if m.Quote != nil {
+		msg["quote"] = map[string]interface{}{
+			"text":     m.Quote.Text,
+			"entities": uctx.convertEntities(m.Quote.Entities),
+			"position": m.Quote.Position,
+			"isManual": m.Quote.IsManual,
 		}
-		msg["photo"] = photos
+	}
+	if m.ReplyToStore != nil {
+		msg["replyToStory"] = uctx.convertStory(m.ReplyToStore)
+	}
+	if m.ViaBot != nil {
+		msg["viaBot"] = uctx.convertUser(m.ViaBot)
+	}
+	if m.EditDate != 0 {
+		msg["editDate"] = m.EditDate
+	}
+	if m.HasProtectedContent {
+		msg["hasProtectedContent"] = true
+	}
+	if m.IsFromOffline {
+		msg["isFromOffline"] = true
+	}
+	if m.MediaGroupID != "" {
+		msg["mediaGroupId"] = m.MediaGroupID
+	}
+	if m.AuthorSignature != "" {
+		msg["authorSignature"] = m.AuthorSignature
+	}
+
+	// Text
+	if len(m.Entities) > 0 {
+		msg["entities"] = uctx.convertEntities(m.Entities)
+	}
+	if m.LinkPreviewOptions != nil {
+		msg["linkPreviewOptions"] = convertLinkPreviewOptions(m.LinkPreviewOptions)
+	}
+	if m.EffectID != "" {
+		msg["effectId"] = m.EffectID
+	}
+
+	// Media
+	if m.Animation != nil {
+		msg["animation"] = convertAnimation(m.Animation)
+	}
+	if m.Audio != nil {
+		msg["audio"] = convertAudio(m.Audio)
 	}
 	if m.Document != nil {
-		msg["document"] = map[string]interface{}{
-			"fileId":       m.Document.FileID,
-			"fileUniqueId": m.Document.FileUniqueID,
-			"fileName":     m.Document.FileName,
-			"mimeType":     m.Document.MimeType,
-			"fileSize":     m.Document.FileSize,
-		}
+		msg["document"] = convertDocument(m.Document)
+	}
+	if m.PaidMedia != nil {
+		msg["paidMedia"] = convertPaidMediaInfo(m.PaidMedia)
+	}
+	if len(m.Photo) > 0 {
+		msg["photo"] = convertPhotoSizes(m.Photo)
 	}
 	if m.Sticker != nil {
-		sticker := map[string]interface{}{
-			"fileId":       m.Sticker.FileID,
-			"fileUniqueId": m.Sticker.FileUniqueID,
-			"width":        m.Sticker.Width,
-			"height":       m.Sticker.Height,
-			"isAnimated":   m.Sticker.IsAnimated,
-			"isVideo":      m.Sticker.IsVideo,
-			"type":         m.Sticker.Type,
-			"emoji":        m.Sticker.Emoji,
-			"setName":      m.Sticker.SetName,
-		}
-		if m.Sticker.Thumbnail != nil {
-			sticker["thumbnail"] = map[string]interface{}{
-				"fileId":       m.Sticker.Thumbnail.FileID,
-				"fileUniqueId": m.Sticker.Thumbnail.FileUniqueID,
-				"width":        m.Sticker.Thumbnail.Width,
-				"height":       m.Sticker.Thumbnail.Height,
-			}
+		msg["sticker"] = convertSticker(m.Sticker)
+	}
+	if m.Story != nil {
+		msg["story"] = uctx.convertStory(m.Story)
+	}
+	if m.Video != nil {
+		msg["video"] = convertVideo(m.Video)
+	}
+	if m.VideoNote != nil {
+		msg["videoNote"] = convertVideoNote(m.VideoNote)
+	}
+	if m.Voice != nil {
+		msg["voice"] = convertVoice(m.Voice)
+	}
+	if len(m.CaptionEntities) > 0 {
+		msg["captionEntities"] = uctx.convertEntities(m.CaptionEntities)
+	}
+	if m.ShowCaptionAboveMedia {
+		msg["showCaptionAboveMedia"] = true
+	}
+	if m.HasMediaSpoiler {
+		msg["hasMediaSpoiler"] = true
+	}
+	if m.Contact != nil {
+		msg["contact"] = convertContact(m.Contact)
+	}
+	if m.Dice != nil {
+		msg["dice"] = map[string]interface{}{
+			"emoji": m.Dice.Emoji,
+			"value": m.Dice.Value,
 		}
-		msg["sticker"] = sticker
 	}
-	// Forward origin (new API)
-	if m.ForwardOrigin != nil {
-		origin := map[string]interface{}{
-			"type": string(m.ForwardOrigin.Type),
-		}
-		switch m.ForwardOrigin.Type {
-		case "user":
-			if m.ForwardOrigin.MessageOriginUser != nil {
-				origin["date"] = m.ForwardOrigin.MessageOriginUser.Date
-				origin["senderUser"] = uctx.convertUser(&m.ForwardOrigin.MessageOriginUser.SenderUser)
-			}
-		case "hidden_user":
-			if m.ForwardOrigin.MessageOriginHiddenUser != nil {
-				origin["date"] = m.ForwardOrigin.MessageOriginHiddenUser.Date
-				origin["senderUserName"] = m.ForwardOrigin.MessageOriginHiddenUser.SenderUserName
+	if m.Game != nil {
+		msg["game"] = uctx.convertGame(m.Game)
+	}
+	if m.Poll != nil {
+		msg["poll"] = uctx.convertPoll(m.Poll)
+	}
+	if m.Venue != nil {
+		msg["venue"] = convertVenue(m.Venue)
+	}
+	if m.Location != nil {
+		msg["location"] = convertLocation(m.Location)
+	}
+
+	// Service messages
+	if len(m.NewChatMembers) > 0 {
+		msg["newChatMembers"] = uctx.convertUsers(m.NewChatMembers)
+	}
+	if m.LeftChatMember != nil {
+		msg["leftChatMember"] = uctx.convertUser(m.LeftChatMember)
+	}
+	if m.NewChatTitle != "" {
+		msg["newChatTitle"] = m.NewChatTitle
+	}
+	if len(m.NewChatPhoto) > 0 {
+		msg["newChatPhoto"] = convertPhotoSizes(m.NewChatPhoto)
+	}
+	if m.DeleteChatPhoto {
+		msg["deleteChatPhoto"] = true
+	}
+	if m.GroupChatCreated {
+		msg["groupChatCreated"] = true
+	}
+	if m.SupergroupChatCreated {
+		msg["supergroupChatCreated"] = true
+	}
+	if m.ChannelChatCreated {
+		msg["channelChatCreated"] = true
+	}
+	if m.MessageAutoDeleteTimerChanged != nil {
+		msg["messageAutoDeleteTimerChanged"] = map[string]interface{}{
+			"messageAutoDeleteTime": m.MessageAutoDeleteTimerChanged.MessageAutoDeleteTime,
+		}
+	}
+	if m.MigrateToChatID != 0 {
+		msg["migrateToChatId"] = m.MigrateToChatID
+	}
+	if m.MigrateFromChatID != 0 {
+		msg["migrateFromChatId"] = m.MigrateFromChatID
+	}
+	if pinned := uctx.convertMaybeInaccessibleMessage(&m.PinnedMessage); pinned != nil {
+		msg["pinnedMessage"] = pinned
+	}
+	if m.Invoice != nil {
+		msg["invoice"] = convertInvoice(m.Invoice)
+	}
+	if m.SuccessfulPayment != nil {
+		msg["successfulPayment"] = convertSuccessfulPayment(m.SuccessfulPayment)
+	}
+	if m.RefundedPayment != nil {
+		msg["refundedPayment"] = convertRefundedPayment(m.RefundedPayment)
+	}
+	if m.UsersShared != nil {
+		users := make([]map[string]interface{}, len(m.UsersShared.Users))
+		for i, u := range m.UsersShared.Users {
+			users[i] = map[string]interface{}{
+				"userId":    u.UserID,
+				"firstName": u.FirstName,
+				"lastName":  u.LastName,
+				"username":  u.Username,
+				"photo":     convertPhotoSizes(u.Photo),
 			}
-		case "chat":
-			if m.ForwardOrigin.MessageOriginChat != nil {
-				origin["date"] = m.ForwardOrigin.MessageOriginChat.Date
-				origin["senderChat"] = uctx.convertChat(m.ForwardOrigin.MessageOriginChat.SenderChat)
-				if m.ForwardOrigin.MessageOriginChat.AuthorSignature != nil {
-					origin["authorSignature"] = *m.ForwardOrigin.MessageOriginChat.AuthorSignature
-				}
+		}
+		msg["usersShared"] = map[string]interface{}{
+			"requestId": m.UsersShared.RequestID,
+			"users":     users,
+		}
+	}
+	if m.ChatShared != nil {
+		msg["chatShared"] = map[string]interface{}{
+			"requestId": m.ChatShared.RequestID,
+			"chatId":    m.ChatShared.ChatID,
+			"title":     m.ChatShared.Title,
+			"username":  m.ChatShared.Username,
+			"photo":     convertPhotoSizes(m.ChatShared.Photo),
+		}
+	}
+	if m.ConnectedWebsite != "" {
+		msg["connectedWebsite"] = m.ConnectedWebsite
+	}
+	if m.WriteAccessAllowed != nil {
+		msg["writeAccessAllowed"] = map[string]interface{}{
+			"fromRequest":        m.WriteAccessAllowed.FromRequest,
+			"webAppName":         m.WriteAccessAllowed.WebAppName,
+			"fromAttachmentMenu": m.WriteAccessAllowed.FromAttachmentMenu,
+		}
+	}
+	if m.PassportData != nil {
+		msg["passportData"] = convertPassportData(m.PassportData)
+	}
+	if m.ProximityAlertTriggered != nil {
+		msg["proximityAlertTriggered"] = map[string]interface{}{
+			"traveler": uctx.convertUser(&m.ProximityAlertTriggered.Traveler),
+			"watcher":  uctx.convertUser(&m.ProximityAlertTriggered.Watcher),
+			"distance": m.ProximityAlertTriggered.Distance,
+		}
+	}
+	if m.BoostAdded != nil {
+		msg["boostAdded"] = map[string]interface{}{
+			"boostCount": m.BoostAdded.BoostCount,
+		}
+	}
+	if m.ChatBackgroundSet != nil {
+		msg["chatBackgroundSet"] = convertChatBackground(m.ChatBackgroundSet)
+	}
+	if m.ForumTopicCreated != nil {
+		msg["forumTopicCreated"] = map[string]interface{}{
+			"name":              m.ForumTopicCreated.Name,
+			"iconColor":         m.ForumTopicCreated.IconColor,
+			"iconCustomEmojiId": m.ForumTopicCreated.IconCustomEmojiID,
+		}
+	}
+	if m.ForumTopicEdited != nil {
+		msg["forumTopicEdited"] = map[string]interface{}{
+			"name":              m.ForumTopicEdited.Name,
+			"iconCustomEmojiId": m.ForumTopicEdited.IconCustomEmojiID,
+		}
+	}
+	// Events without data are exposed as flags
+	if m.ForumTopicClosed != nil {
+		msg["forumTopicClosed"] = true
+	}
+	if m.ForumTopicReopened != nil {
+		msg["forumTopicReopened"] = true
+	}
+	if m.GeneralForumTopicHidden != nil {
+		msg["generalForumTopicHidden"] = true
+	}
+	if m.GeneralForumTopicUnhidden != nil {
+		msg["generalForumTopicUnhidden"] = true
+	}
+	if m.GiveawayCreated != nil {
+		msg["giveawayCreated"] = map[string]interface{}{
+			"prizeStarCount": m.GiveawayCreated.PrizeStarCount,
+		}
+	}
+	if m.Giveaway != nil {
+		msg["giveaway"] = uctx.convertGiveaway(m.Giveaway)
+	}
+	if m.GiveawayWinners != nil {
+		msg["giveawayWinners"] = uctx.convertGiveawayWinners(m.GiveawayWinners)
+	}
+	if m.GiveawayCompleted != nil {
+		completed := map[string]interface{}{
+			"winnerCount":         m.GiveawayCompleted.WinnerCount,
+			"unclaimedPrizeCount": m.GiveawayCompleted.UnclaimedPrizeCount,
+			"isStarGiveaway":      m.GiveawayCompleted.IsStarGiveaway,
+		}
+		if m.GiveawayCompleted.GiveawayMessage != nil {
+			completed["giveawayMessage"] = uctx.convertMessage(m.GiveawayCompleted.GiveawayMessage)
+		}
+		msg["giveawayCompleted"] = completed
+	}
+	// Named after the current Bot API fields, which replaced voice chats
+	if m.VoiceChatScheduled != nil {
+		msg["videoChatScheduled"] = map[string]interface{}{
+			"startDate": m.VoiceChatScheduled.StartDate,
+		}
+	}
+	if m.VoiceChatStarted != nil {
+		msg["videoChatStarted"] = true
+	}
+	if m.VoiceChatEnded != nil {
+		msg["videoChatEnded"] = map[string]interface{}{
+			"duration": m.VoiceChatEnded.Duration,
+		}
+	}
+	if m.VoiceChatParticipantsInvited != nil {
+		msg["videoChatParticipantsInvited"] = map[string]interface{}{
+			"users": uctx.convertUsers(m.VoiceChatParticipantsInvited.Users),
+		}
+	}
+	if m.WebAppData != nil {
+		msg["webAppData"] = map[string]interface{}{
+			"data":       m.WebAppData.Data,
+			"buttonText": m.WebAppData.ButtonText,
+		}
+	}
+	if len(m.ReplyMarkup.InlineKeyboard) > 0 {
+		msg["replyMarkup"] = map[string]interface{}{
+			"inlineKeyboard": convertInlineKeyboard(m.ReplyMarkup.InlineKeyboard),
+		}
+	}
+	return msg
+}
+
+// convertMaybeInaccessibleMessage converts a message the bot may no longer
+// be able to access. Inaccessible messages only keep chat and messageId and
+// have date 0. Returns nil when no message is set.
+func (uctx *UpdateContext) convertMaybeInaccessibleMessage(m *models.MaybeInaccessibleMessage) map[string]interface{} {
+	switch {
+	case m.Message != nil:
+		return uctx.convertMessage(m.Message)
+	case m.InaccessibleMessage != nil:
+		return map[string]interface{}{
+			"messageId": m.InaccessibleMessage.MessageID,
+			"date":      0,
+			"chat":      uctx.convertChat(m.InaccessibleMessage.Chat),
+		}
+	}
+	return nil
+}
+
+func (uctx *UpdateContext) convertMessageOrigin(o *models.MessageOrigin) map[string]interface{} {
+	origin := map[string]interface{}{
+		"type": string(o.Type),
+	}
+	switch o.Type {
+	case "user":
+		if o.MessageOriginUser != nil {
+			origin["date"] = o.MessageOriginUser.Date
+			origin["senderUser"] = uctx.convertUser(&o.MessageOriginUser.SenderUser)
+		}
+	case "hidden_user":
+		if o.MessageOriginHiddenUser != nil {
+			origin["date"] = o.MessageOriginHiddenUser.Date
+			origin["senderUserName"] = o.MessageOriginHiddenUser.SenderUserName
+		}
+	case "chat":
+		if o.MessageOriginChat != nil {
+			origin["date"] = o.MessageOriginChat.Date
+			origin["senderChat"] = uctx.convertChat(o.MessageOriginChat.SenderChat)
+			if o.MessageOriginChat.AuthorSignature != nil {
+				origin["authorSignature"] = *o.MessageOriginChat.AuthorSignature
 			}
-		case "channel":
-			if m.ForwardOrigin.MessageOriginChannel != nil {
-				origin["date"] = m.ForwardOrigin.MessageOriginChannel.Date
-				origin["chat"] = uctx.convertChat(m.ForwardOrigin.MessageOriginChannel.Chat)
-				origin["messageId"] = m.ForwardOrigin.MessageOriginChannel.MessageID
-				if m.ForwardOrigin.MessageOriginChannel.AuthorSignature != nil {
-					origin["authorSignature"] = *m.ForwardOrigin.MessageOriginChannel.AuthorSignature
-				}
+		}
+	case "channel":
+		if o.MessageOriginChannel != nil {
+			origin["date"] = o.MessageOriginChannel.Date
+			origin["chat"] = uctx.convertChat(o.MessageOriginChannel.Chat)
+			origin["messageId"] = o.MessageOriginChannel.MessageID
+			if o.MessageOriginChannel.AuthorSignature != nil {
+				origin["authorSignature"] = *o.MessageOriginChannel.AuthorSignature
 			}
 		}
-		msg["forwardOrigin"] = origin
 	}
-	return msg
+	return origin
+}
+
+// convertExternalReply converts info about a replied message from another
+// chat or forum topic
+func (uctx *UpdateContext) convertExternalReply(r *models.ExternalReplyInfo) map[string]interface{} {
+	reply := map[string]interface{}{
+		"origin": uctx.convertMessageOrigin(&r.Origin),
+	}
+	if r.Chat != nil {
+		reply["chat"] = uctx.convertChat(*r.Chat)
+	}
+	if r.MessageID != 0 {
+		reply["messageId"] = r.MessageID
+	}
+	if r.LinkPreviewOptions != nil {
+		reply["linkPreviewOptions"] = convertLinkPreviewOptions(r.LinkPreviewOptions)
+	}
+	if r.Animation != nil {
+		reply["animation"] = convertAnimation(r.Animation)
+	}
+	if r.Audio != nil {
+		reply["audio"] = convertAudio(r.Audio)
+	}
+	if r.Document != nil {
+		reply["document"] = convertDocument(r.Document)
+	}
+	if r.PaidMedia != nil {
+		reply["paidMedia"] = convertPaidMediaInfo(r.PaidMedia)
+	}
+	if len(r.Photo) > 0 {
+		reply["photo"] = convertPhotoSizes(r.Photo)
+	}
+	if r.Sticker != nil {
+		reply["sticker"] = convertSticker(r.Sticker)
+	}
+	if r.Story != nil {
+		reply["story"] = uctx.convertStory(r.Story)
+	}
+	if r.Video != nil {
+		reply["video"] = convertVideo(r.Video)
+	}
+	if r.VideoNote != nil {
+		reply["videoNote"] = convertVideoNote(r.VideoNote)
+	}
+	if r.Voice != nil {
+		reply["voice"] = convertVoice(r.Voice)
+	}
+	if r.HasMediaSpoiler {
+		reply["hasMediaSpoiler"] = true
+	}
+	if r.Contact != nil {
+		reply["contact"] = convertContact(r.Contact)
+	}
+	if r.Dice != nil {
+		reply["dice"] = map[string]interface{}{
+			"emoji": r.Dice.Emoji,
+			"value": r.Dice.Value,
+		}
+	}
+	if r.Game != nil {
+		reply["game"] = uctx.convertGame(r.Game)
+	}
+	if r.Giveaway != nil {
+		reply["giveaway"] = uctx.convertGiveaway(r.Giveaway)
+	}
+	if r.GiveawayWinners != nil {
+		reply["giveawayWinners"] = uctx.convertGiveawayWinners(r.GiveawayWinners)
+	}
+	if r.Invoice != nil {
+		reply["invoice"] = convertInvoice(r.Invoice)
+	}
+	if r.Location != nil {
+		reply["location"] = convertLocation(r.Location)
+	}
+	if r.Poll != nil {
+		reply["poll"] = uctx.convertPoll(r.Poll)
+	}
+	if r.Venue != nil {
+		reply["venue"] = convertVenue(r.Venue)
+	}
+	return reply
+}
+
+func (uctx *UpdateContext) convertMessageReaction(r *models.MessageReactionUpdated) map[string]interface{} {
+	reaction := map[string]interface{}{
+		"chat":        uctx.convertChat(r.Chat),
+		"messageId":   r.MessageID,
+		"date":        r.Date,
+		"oldReaction": convertReactionTypes(r.OldReaction),
+		"newReaction": convertReactionTypes(r.NewReaction),
+	}
+	if r.User != nil {
+		reaction["user"] = uctx.convertUser(r.User)
+	}
+	if r.ActorChat != nil {
+		reaction["actorChat"] = uctx.convertChat(*r.ActorChat)
+	}
+	return reaction
+}
+
+func convertReactionTypes(reactions []models.ReactionType) []map[string]interface{} {
+	result := make([]map[string]interface{}, len(reactions))
+	for i := range reactions {
+		result[i] = convertReactionType(&reactions[i])
+	}
+	return result
+}
+
+func convertReactionType(r *models.ReactionType) map[string]interface{} {
+	reaction := map[string]interface{}{
+		"type": string(r.Type),
+	}
+	switch {
+	case r.ReactionTypeEmoji != nil:
+		reaction["emoji"] = r.ReactionTypeEmoji.Emoji
+	case r.ReactionTypeCustomEmoji != nil:
+		reaction["customEmojiId"] = r.ReactionTypeCustomEmoji.CustomEmojiID
+	}
+	return reaction
+}
+
+func (uctx *UpdateContext) convertChatBoostSource(s *models.ChatBoostSource) map[string]interface{} {
+	source := map[string]interface{}{
+		"source": string(s.Source),
+	}
+	switch {
+	case s.ChatBoostSourcePremium != nil:
+		source["user"] = uctx.convertUser(&s.ChatBoostSourcePremium.User)
+	case s.ChatBoostSourceGiftCode != nil:
+		source["user"] = uctx.convertUser(&s.ChatBoostSourceGiftCode.User)
+	case s.ChatBoostSourceGiveaway != nil:
+		g := s.ChatBoostSourceGiveaway
+		source["giveawayMessageId"] = g.GiveawayMessageID
+		source["prizeStarCount"] = g.PrizeStarCount
+		source["isUnclaimed"] = g.IsUnclaimed
+		if g.User.ID != 0 {
+			source["user"] = uctx.convertUser(&g.User)
+		}
+	}
+	return source
 }
 
 func (uctx *UpdateContext) convertChat(c models.Chat) map[string]interface{} {
@@ -131,6 +701,7 @@ func (uctx *UpdateContext) convertChat(c models.Chat) map[string]interface{} {
 		"username":  c.Username,
 		"firstName": c.FirstName,
 		"lastName":  c.LastName,
+		"isForum":   c.IsForum,
 	}
 }
 
@@ -147,3 +718,444 @@ func (uctx *UpdateContext) convertUser(u *models.User) map[string]interface{} {
 		"languageCode": u.LanguageCode,
 	}
 }
+
+func (uctx *UpdateContext) convertUsers(users []models.User) []map[string]interface{} {
+	result := make([]map[string]interface{}, len(users))
+	for i := range users {
+		result[i] = uctx.convertUser(&users[i])
+	}
+	return result
+}
+
+func convertLocation(l *models.Location) map[string]interface{} {
+	return map[string]interface{}{
+		"latitude":           l.Latitude,
+		"longitude":          l.Longitude,
+		"horizontalAccuracy": l.HorizontalAccuracy,
+		"livePeriod":         l.LivePeriod,
+		"heading":            l.Heading,
+	}
+}
+
+func convertVenue(v *models.Venue) map[string]interface{} {
+	return map[string]interface{}{
+		"location":        convertLocation(&v.Location),
+		"title":           v.Title,
+		"address":         v.Address,
+		"foursquareId":    v.FoursquareID,
+		"foursquareType":  v.FoursquareType,
+		"googlePlaceId":   v.GooglePlaceID,
+		"googlePlaceType": v.GooglePlaceType,
+	}
+}
+
+func convertContact(c *models.Contact) map[string]interface{} {
+	return map[string]interface{}{
+		"phoneNumber": c.PhoneNumber,
+		"firstName":   c.FirstName,
+		"lastName":    c.LastName,
+		"userId":      c.UserID,
+		"vcard":       c.VCard,
+	}
+}
+
+func (uctx *UpdateContext) convertEntities(entities []models.MessageEntity) []map[string]interface{} {
+	result := make([]map[string]interface{}, len(entities))
+	for i, e := range entities {
+		entity := map[string]interface{}{
+			"type":   string(e.Type),
+			"offset": e.Offset,
+			"length": e.Length,
+		}
+		if e.URL != "" {
+			entity["url"] = e.URL
+		}
+		if e.User != nil {
+			entity["user"] = uctx.convertUser(e.User)
+		}
+		if e.Language != "" {
+			entity["language"] = e.Language
+		}
+		if e.CustomEmojiID != "" {
+			entity["customEmojiId"] = e.CustomEmojiID
+		}
+		result[i] = entity
+	}
+	return result
+}
+
+func convertLinkPreviewOptions(o *models.LinkPreviewOptions) map[string]interface{} {
+	options := map[string]interface{}{}
+	if o.IsDisabled != nil {
+		options["isDisabled"] = *o.IsDisabled
+	}
+	if o.URL != nil {
+		options["url"] = *o.URL
+	}
+	if o.PreferSmallMedia != nil {
+		options["preferSmallMedia"] = *o.PreferSmallMedia
+	}
+	if o.PreferLargeMedia != nil {
+		options["preferLargeMedia"] = *o.PreferLargeMedia
+	}
+	if o.ShowAboveText != nil {
+		options["showAboveText"] = *o.ShowAboveText
+	}
+	return options
+}
+
+func (uctx *UpdateContext) convertPoll(p *models.Poll) map[string]interface{} {
+	options := make([]map[string]interface{}, len(p.Options))
+	for i, o := range p.Options {
+		options[i] = map[string]interface{}{
+			"text":         o.Text,
+			"textEntities": uctx.convertEntities(o.TextEntities),
+			"voterCount":   o.VoterCount,
+		}
+	}
+	return map[string]interface{}{
+		"id":                    p.ID,
+		"question":              p.Question,
+		"questionEntities":      uctx.convertEntities(p.QuestionEntities),
+		"options":               options,
+		"totalVoterCount":       p.TotalVoterCount,
+		"isClosed":              p.IsClosed,
+		"isAnonymous":           p.IsAnonymous,
+		"type":                  p.Type,
+		"allowsMultipleAnswers": p.AllowsMultipleAnswers,
+		"correctOptionId":       p.CorrectOptionID,
+		"explanation":           p.Explanation,
+		"explanationEntities":   uctx.convertEntities(p.ExplanationEntities),
+		"openPeriod":            p.OpenPeriod,
+		"closeDate":             p.CloseDate,
+	}
+}
+
+func (uctx *UpdateContext) convertGame(g *models.Game) map[string]interface{} {
+	game := map[string]interface{}{
+		"title":        g.Title,
+		"description":  g.Description,
+		"photo":        convertPhotoSizes(g.Photo),
+		"text":         g.Text,
+		"textEntities": uctx.convertEntities(g.TextEntities),
+	}
+	if g.Animation != nil {
+		game["animation"] = convertAnimation(g.Animation)
+	}
+	return game
+}
+
+func (uctx *UpdateContext) convertStory(s *models.Story) map[string]interface{} {
+	return map[string]interface{}{
+		"id":   s.ID,
+		"chat": uctx.convertChat(s.Chat),
+	}
+}
+
+func (uctx *UpdateContext) convertGiveaway(g *models.Giveaway) map[string]interface{} {
+	chats := make([]map[string]interface{}, len(g.Chats))
+	for i, c := range g.Chats {
+		chats[i] = uctx.convertChat(c)
+	}
+	return map[string]interface{}{
+		"chats":                         chats,
+		"winnersSelectionDate":          g.WinnersSelectionDate,
+		"winnerCount":                   g.WinnerCount,
+		"onlyNewMembers":                g.OnlyNewMembers,
+		"hasPublicWinners":              g.HasPublicWinners,
+		"prizeDescription":              g.PrizeDescription,
+		"countryCodes":                  g.CountryCodes,
+		"prizeStarCount":                g.PrizeStarCount,
+		"premiumSubscriptionMonthCount": g.PremiumSubscriptionMonthCount,
+	}
+}
+
+func (uctx *UpdateContext) convertGiveawayWinners(w *models.GiveawayWinners) map[string]interface{} {
+	return map[string]interface{}{
+		"chat":                          uctx.convertChat(w.Chat),
+		"giveawayMessageId":             w.GiveawayMessageID,
+		"winnersSelectionDate":          w.WinnersSelectionDate,
+		"winnerCount":                   w.WinnerCount,
+		"winners":                       uctx.convertUsers(w.Winners),
+		"additionalChatCount":           w.AdditionalChatCount,
+		"premiumSubscriptionMonthCount": w.PremiumSubscriptionMonthCount,
+		"unclaimedPrizeCount":           w.UnclaimedPrizeCount,
+		"prizeStarCount":                w.PrizeStarCount,
+		"onlyNewMembers":                w.OnlyNewMembers,
+		"wasRefunded":                   w.WasRefunded,
+		"prizeDescription":              w.PrizeDescription,
+	}
+}
+
+func convertInlineKeyboard(rows [][]models.InlineKeyboardButton) [][]map[string]interface{} {
+	result := make([][]map[string]interface{}, len(rows))
+	for i, row := range rows {
+		result[i] = make([]map[string]interface{}, len(row))
+		for j, b := range row {
+			button := map[string]interface{}{
+				"text": b.Text,
+			}
+			if b.URL != "" {
+				button["url"] = b.URL
+			}
+			if b.CallbackData != "" {
+				button["callbackData"] = b.CallbackData
+			}
+			if b.WebApp != nil {
+				button["webApp"] = map[string]interface{}{"url": b.WebApp.URL}
+			}
+			if b.LoginURL != nil {
+				button["loginUrl"] = map[string]interface{}{"url": b.LoginURL.URL}
+			}
+			if b.SwitchInlineQuery != "" {
+				button["switchInlineQuery"] = b.SwitchInlineQuery
+			}
+			if b.SwitchInlineQueryCurrentChat != "" {
+				button["switchInlineQueryCurrentChat"] = b.SwitchInlineQueryCurrentChat
+			}
+			if b.CopyText.Text != "" {
+				button["copyText"] = map[string]interface{}{"text": b.CopyText.Text}
+			}
+			if b.CallbackGame != nil {
+				button["callbackGame"] = true
+			}
+			if b.Pay {
+				button["pay"] = true
+			}
+			result[i][j] = button
+		}
+	}
+	return result
+}
+
+func convertPassportData(p *models.PassportData) map[string]interface{} {
+	elements := make([]map[string]interface{}, len(p.Data))
+	for i, e := range p.Data {
+		element := map[string]interface{}{
+			"type":        e.Type,
+			"data":        e.Data,
+			"phoneNumber": e.PhoneNumber,
+			"email":       e.Email,
+			"files":       convertPassportFiles(e.Files),
+			"translation": convertPassportFiles(e.Translation),
+			"hash":        e.Hash,
+		}
+		if e.FrontSide != nil {
+			element["frontSide"] = convertPassportFile(e.FrontSide)
+		}
+		if e.ReverseSide != nil {
+			element["reverseSide"] = convertPassportFile(e.ReverseSide)
+		}
+		if e.Selfie != nil {
+			element["selfie"] = convertPassportFile(e.Selfie)
+		}
+		elements[i] = element
+	}
+	return map[string]interface{}{
+		"data": elements,
+		"credentials": map[string]interface{}{
+			"data":   p.Credentials.Data,
+			"hash":   p.Credentials.Hash,
+			"secret": p.Credentials.Secret,
+		},
+	}
+}
+
+func convertPassportFiles(files []models.PassportFile) []map[string]interface{} {
+	result := make([]map[string]interface{}, len(files))
+	for i := range files {
+		result[i] = convertPassportFile(&files[i])
+	}
+	return result
+}
+
+func convertPassportFile(f *models.PassportFile) map[string]interface{} {
+	return map[string]interface{}{
+		"fileId":       f.FileID,
+		"fileUniqueId": f.FileUniqueID,
+		"fileSize":     f.FileSize,
+		"fileDate":     f.FileDate,
+	}
+}
+
+func convertChatBackground(b *models.ChatBackground) map[string]interface{} {
+	background := map[string]interface{}{
+		"type": string(b.Type),
+	}
+	switch {
+	case b.Fill != nil:
+		background["fill"] = convertBackgroundFill(&b.Fill.Fill)
+		background["darkThemeDimming"] = b.Fill.DarkThemeDimming
+	case b.Wallpaper != nil:
+		background["document"] = convertDocument(&b.Wallpaper.Document)
+		background["darkThemeDimming"] = b.Wallpaper.DarkThemeDimming
+		background["isBlurred"] = b.Wallpaper.IsBlurred
+		background["isMoving"] = b.Wallpaper.IsMoving
+	case b.Pattern != nil:
+		background["document"] = convertDocument(&b.Pattern.Document)
+		background["fill"] = convertBackgroundFill(&b.Pattern.Fill)
+		background["intensity"] = b.Pattern.Intensity
+		background["isInverted"] = b.Pattern.IsInverted
+		background["isMoving"] = b.Pattern.IsMoving
+	case b.Theme != nil:
+		background["themeName"] = b.Theme.ThemeName
+	}
+	return background
+}
+
+func convertBackgroundFill(f *models.BackgroundFill) map[string]interface{} {
+	fill := map[string]interface{}{
+		"type": string(f.Type),
+	}
+	switch {
+	case f.Solid != nil:
+		fill["color"] = f.Solid.Color
+	case f.Gradient != nil:
+		fill["topColor"] = f.Gradient.TopColor
+		fill["bottomColor"] = f.Gradient.BottomColor
+		fill["rotationAngle"] = f.Gradient.RotationAngle
+	case f.FreeformGradient != nil:
+		fill["colors"] = f.FreeformGradient.Colors
+	}
+	return fill
+}
+
+// Media
+
+func convertPhotoSize(p *models.PhotoSize) map[string]interface{} {
+	return map[string]interface{}{
+		"fileId":       p.FileID,
+		"fileUniqueId": p.FileUniqueID,
+		"width":        p.Width,
+		"height":       p.Height,
+		"fileSize":     p.FileSize,
+	}
+}
+
+func convertPhotoSizes(sizes []models.PhotoSize) []map[string]interface{} {
+	result := make([]map[string]interface{}, len(sizes))
+	for i := range sizes {
+		result[i] = convertPhotoSize(&sizes[i])
+	}
+	return result
+}
+
+// withThumbnail adds the thumbnail of a media object when it has one
+func withThumbnail(media map[string]interface{}, thumbnail *models.PhotoSize) map[string]interface{} {
+	if thumbnail != nil {
+		media["thumbnail"] = convertPhotoSize(thumbnail)
+	}
+	return media
+}
+
+func convertAnimation(a *models.Animation) map[string]interface{} {
+	return withThumbnail(map[string]interface{}{
+		"fileId":       a.FileID,
+		"fileUniqueId": a.FileUniqueID,
+		"width":        a.Width,
+		"height":       a.Height,
+		"duration":     a.Duration,
+		"fileName":     a.FileName,
+		"mimeType":     a.MimeType,
+		"fileSize":     a.FileSize,
+	}, a.Thumbnail)
+}
+
+func convertAudio(a *models.Audio) map[string]interface{} {
+	return withThumbnail(map[string]interface{}{
+		"fileId":       a.FileID,
+		"fileUniqueId": a.FileUniqueID,
+		"duration":     a.Duration,
+		"performer":    a.Performer,
+		"title":        a.Title,
+		"fileName":     a.FileName,
+		"mimeType":     a.MimeType,
+		"fileSize":     a.FileSize,
+	}, a.Thumbnail)
+}
+
+func convertDocument(d *models.Document) map[string]interface{} {
+	return withThumbnail(map[string]interface{}{
+		"fileId":       d.FileID,
+		"fileUniqueId": d.FileUniqueID,
+		"fileName":     d.FileName,
+		"mimeType":     d.MimeType,
+		"fileSize":     d.FileSize,
+	}, d.Thumbnail)
+}
+
+func convertSticker(s *models.Sticker) map[string]interface{} {
+	sticker := withThumbnail(map[string]interface{}{
+		"fileId":       s.FileID,
+		"fileUniqueId": s.FileUniqueID,
+		"width":        s.Width,
+		"height":       s.Height,
+		"isAnimated":   s.IsAnimated,
+		"isVideo":      s.IsVideo,
+		"type":         s.Type,
+		"emoji":        s.Emoji,
+		"setName":      s.SetName,
+		"fileSize":     s.FileSize,
+	}, s.Thumbnail)
+	if s.CustomEmojiID != "" {
+		sticker["customEmojiId"] = s.CustomEmojiID
+	}
+	return sticker
+}
+
+func convertVideo(v *models.Video) map[string]interface{} {
+	return withThumbnail(map[string]interface{}{
+		"fileId":       v.FileID,
+		"fileUniqueId": v.FileUniqueID,
+		"width":        v.Width,
+		"height":       v.Height,
+		"duration":     v.Duration,
+		"fileName":     v.FileName,
+		"mimeType":     v.MimeType,
+		"fileSize":     v.FileSize,
+	}, v.Thumbnail)
+}
+
+func convertVideoNote(v *models.VideoNote) map[string]interface{} {
+	return withThumbnail(map[string]interface{}{
+		"fileId":       v.FileID,
+		"fileUniqueId": v.FileUniqueID,
+		"length":       v.Length,
+		"duration":     v.Duration,
+		"fileSize":     v.FileSize,
+	}, v.Thumbnail)
+}
+
+func convertVoice(v *models.Voice) map[string]interface{} {
+	return map[string]interface{}{
+		"fileId":       v.FileID,
+		"fileUniqueId": v.FileUniqueID,
+		"duration":     v.Duration,
+		"mimeType":     v.MimeType,
+		"fileSize":     v.FileSize,
+	}
+}
+
+func convertPaidMediaInfo(p *models.PaidMediaInfo) map[string]interface{} {
+	media := make([]map[string]interface{}, len(p.PaidMedia))
+	for i, m := range p.PaidMedia {
+		item := map[string]interface{}{
+			"type": string(m.Type),
+		}
+		switch {
+		case m.Preview != nil:
+			item["width"] = m.Preview.Width
+			item["height"] = m.Preview.Height
+			item["duration"] = m.Preview.Duration
+		case m.Photo != nil:
+			item["photo"] = convertPhotoSizes(m.Photo.Photo)
+		case m.Video != nil:
+			item["video"] = convertVideo(&m.Video.Video)
+		}
+		media[i] = item
+	}
+	return map[string]interface{}{
+		"starCount": p.StarCount,
+		"paidMedia": media,
+	}
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/dop251/goja"
+	"github.com/go-telegram/bot/models"
+	"github.com/spf13/cast"
+)
+
+// Entity types the bot library has no constants for
+const (
+	entityTypeSpoiler              models.MessageEntityType = "spoiler"
+	entityTypeBlockquote           models.MessageEntityType = "blockquote"
+	entityTypeExpandableBlockquote models.MessageEntityType = "expandable_blockquote"
+)
+
+// markdownV2Special are the characters MarkdownV2 requires to be escaped
+// outside of code
+const markdownV2Special = "_*[]()~`>#+-=|{}.!\\"
+
+// entityRenderer wraps entity contents in the markup of one parse mode
+type entityRenderer struct {
+	escape func(text string, e *models.MessageEntity) string
+	wrap   func(e *models.MessageEntity, inner string, parent *models.MessageEntity) string
+}
+
+// renderEntities re-renders text with its entities. Offsets and lengths are
+// in UTF-16 code units, as Telegram sends them. Nested entities are rendered
+// inside their parent; an entity crossing its parent's end is closed there
+// and reopened after it.
+func renderEntities(text string, entities []models.MessageEntity, r entityRenderer) string {
+	units := utf16.Encode([]rune(text))
+	sorted := make([]models.MessageEntity, 0, len(entities))
+	for _, e := range entities {
+		if e.Length <= 0 || e.Offset < 0 || e.Offset >= len(units) {
+			continue
+		}
+		if e.Offset+e.Length > len(units) {
+			e.Length = len(units) - e.Offset
+		}
+		sorted = append(sorted, e)
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return entityBefore(sorted[i], sorted[j])
+	})
+	return renderRange(units, 0, len(units), nestEntities(sorted), nil, r)
+}
+
+// entityBefore orders entities by offset, outer entities first when several
+// start at the same offset
+func entityBefore(a, b models.MessageEntity) bool {
+	if a.Offset != b.Offset {
+		return a.Offset < b.Offset
+	}
+	return a.Length > b.Length
+}
+
+// nestEntities splits every entity that crosses the end of an entity it
+// starts in at that end, so entities either nest or do not overlap. The rest
+// of a split entity is reopened where the enclosing one ends. entities must
+// be sorted with entityBefore; the result is sorted the same way.
+func nestEntities(entities []models.MessageEntity) []models.MessageEntity {
+	pending := slices.Clone(entities)
+	result := make([]models.MessageEntity, 0, len(entities))
+	for len(pending) > 0 {
+		e := pending[0]
+		pending = pending[1:]
+
+		end := e.Offset + e.Length
+		cut := end
+		for _, outer := range result {
+			outerEnd := outer.Offset + outer.Length
+			if outer.Offset <= e.Offset && e.Offset < outerEnd && outerEnd < cut {
+				cut = outerEnd
+			}
+		}
+		if cut < end {
+			rest := e
+			rest.Offset, rest.Length = cut, end-cut
+			e.Length = cut - e.Offset
+			i := sort.Search(len(pending), func(i int) bool {
+				return !entityBefore(pending[i], rest)
+			})
+			pending = slices.Insert(pending, i, rest)
+		}
+		result = append(result, e)
+	}
+	return result
+}
+
+func renderRange(units []uint16, start, end int, entities []models.MessageEntity, parent *models.MessageEntity, r entityRenderer) string {
+	var b strings.Builder
+	pos := start
+	for i := 0; i < len(entities); {
+		e := entities[i]
+		if e.Offset < pos {
+			// Starts inside an entity that was already rendered
+			i++
+			continue
+		}
+		entityEnd := e.Offset + e.Length
+		if entityEnd > end {
+			entityEnd = end
+			e.Length = end - e.Offset
+		}
+		b.WriteString(r.escape(string(utf16.Decode(units[pos:e.Offset])), parent))
+
+		j := i + 1
+		for j < len(entities) && entities[j].Offset < entityEnd {
+			j++
+		}
+		inner := renderRange(units, e.Offset, entityEnd, entities[i+1:j], &e, r)
+		b.WriteString(r.wrap(&e, inner, parent))
+		pos = entityEnd
+		i = j
+	}
+	b.WriteString(r.escape(string(utf16.Decode(units[pos:end])), parent))
+	return b.String()
+}
+
+// htmlRenderer renders entities as Telegram HTML
+var htmlRenderer = entityRenderer{
+	escape: func(text string, _ *models.MessageEntity) string {
+		return html.EscapeString(text)
+	},
+	wrap: func(e *models.MessageEntity, inner string, _ *models.MessageEntity) string {
+		switch e.Type {
+		case models.MessageEntityTypeBold:
+			return "<b>" + inner + "</b>"
+		case models.MessageEntityTypeItalic:
+			return "<i>" + inner + "</i>"
+		case models.MessageEntityTypeUnderline:
+			return "<u>" + inner + "</u>"
+		case models.MessageEntityTypeStrikethrough:
+			return "<s>" + inner + "</s>"
+		case entityTypeSpoiler:
+			return "<tg-spoiler>" + inner + "</tg-spoiler>"
+		case models.MessageEntityTypeCode:
+			return "<code>" + inner + "</code>"
+		case models.MessageEntityTypePre:
+			if e.Language != "" {
+				return fmt.Sprintf(`<pre><code class="language-%s">%s</code></pre>`, html.EscapeString(e.Language), inner)
+			}
+			return "<pre>" + inner + "</pre>"
+		case models.MessageEntityTypeTextLink:
+			return fmt.Sprintf(`<a href="%s">%s</a>`, html.EscapeString(e.URL), inner)
+		case models.MessageEntityTypeTextMention:
+			if e.User != nil {
+				return fmt.Sprintf(`<a href="tg://user?id=%d">%s</a>`, e.User.ID, inner)
+			}
+		case models.MessageEntityTypeCustomEmoji:
+			return fmt.Sprintf(`<tg-emoji emoji-id="%s">%s</tg-emoji>`, html.EscapeString(e.CustomEmojiID), inner)
+		case entityTypeBlockquote:
+			return "<blockquote>" + inner + "</blockquote>"
+		case entityTypeExpandableBlockquote:
+			return "<blockquote expandable>" + inner + "</blockquote>"
+		}
+		// Mentions, hashtags, URLs and the like are detected from plain text
+		return inner
+	},
+}
+
+// markdownV2Renderer renders entities as Telegram MarkdownV2
+var markdownV2Renderer = entityRenderer{
+	escape: func(text string, parent *models.MessageEntity) string {
+		special := markdownV2Special
+		if parent != nil && (parent.Type == models.MessageEntityTypeCode || parent.Type == models.MessageEntityTypePre) {
+			special = "`\\"
+		}
+		var b strings.Builder
+		for _, r := range text {
+			if strings.ContainsRune(special, r) {
+				b.WriteByte('\\')
+			}
+			b.WriteRune(r)
+		}
+		return b.String()
+	},
+	wrap: func(e *models.MessageEntity, inner string, parent *models.MessageEntity) string {
+		switch e.Type {
+		case models.MessageEntityTypeBold:
+			return "*" + inner + "*"
+		case models.MessageEntityTypeItalic:
+			// "___" is read as underline first; \r separates an italic end
+			// from the end of the underline around it
+			if parent != nil && parent.Type == models.MessageEntityTypeUnderline && e.Offset+e.Length == parent.Offset+parent.Length {
+				return "_" + inner + "_\r"
+			}
+			return "_" + inner + "_"
+		case models.MessageEntityTypeUnderline:
+			return "__" + inner + "__"
+		case models.MessageEntityTypeStrikethrough:
+			return "~" + inner + "~"
+		case entityTypeSpoiler:
+			return "||" + inner + "||"
+		case models.MessageEntityTypeCode:
+			return "`" + inner + "`"
+		case models.MessageEntityTypePre:
+			return "```" + e.Language + "\n" + inner + "\n```"
+		case models.MessageEntityTypeTextLink:
+			return "[" + inner + "](" + escapeMarkdownV2URL(e.URL) + ")"
+		case models.MessageEntityTypeTextMention:
+			if e.User != nil {
+				return fmt.Sprintf("[%s](tg://user?id=%d)", inner, e.User.ID)
+			}
+		case models.MessageEntityTypeCustomEmoji:
+			return "![" + inner + "](tg://emoji?id=" + e.CustomEmojiID + ")"
+		case entityTypeBlockquote:
+			return quoteLines(inner)
+		case entityTypeExpandableBlockquote:
+			return "**" + quoteLines(inner) + "||"
+		}
+		return inner
+	},
+}
+
+// escapeMarkdownV2URL escapes the characters that end a MarkdownV2 link
+func escapeMarkdownV2URL(url string) string {
+	return strings.NewReplacer(`\`, `\\`, `)`, `\)`).Replace(url)
+}
+
+// quoteLines prefixes every line with the MarkdownV2 quote marker
+func quoteLines(text string) string {
+	return ">" + strings.ReplaceAll(text, "\n", "\n>")
+}
+
+// messageText returns the text of a message and its entities, falling back
+// to the caption for media messages
+func messageText(m *models.Message) (string, []models.MessageEntity) {
+	if m.Text != "" {
+		return m.Text, m.Entities
+	}
+	return m.Caption, m.CaptionEntities
+}
+
+// messageObject creates ctx.message: the converted message with html() and
+// markdownV2() helpers. The helpers are not enumerable, so the object can
+// still be stored in a session or serialized.
+func (uctx *UpdateContext) messageObject(m *models.Message) *goja.Object {
+	rt := uctx.instance.runtime
+	obj := rt.NewObject()
+	for key, value := range uctx.convertMessage(m) {
+		obj.Set(key, value)
+	}
+	text, entities := messageText(m)
+	obj.DefineDataProperty("html", rt.ToValue(func() string {
+		return renderEntities(text, entities, htmlRenderer)
+	}), goja.FLAG_TRUE, goja.FLAG_TRUE, goja.FLAG_FALSE)
+	obj.DefineDataProperty("markdownV2", rt.ToValue(func() string {
+		return renderEntities(text, entities, markdownV2Renderer)
+	}), goja.FLAG_TRUE, goja.FLAG_TRUE, goja.FLAG_FALSE)
+	return obj
+}
+
+// parseEntities reads entities in the shape of message.entities, so entities
+// of a received message can be sent back as is
+func parseEntities(value interface{}) []models.MessageEntity {
+	var items []map[string]interface{}
+	switch v := value.(type) {
+	case []map[string]interface{}:
+		items = v
+	case []interface{}:
+		for _, item := range v {
+			if e, ok := item.(map[string]interface{}); ok {
+				items = append(items, e)
+			}
+		}
+	default:
+		return nil
+	}
+	entities := make([]models.MessageEntity, 0, len(items))
+	for _, e := range items {
+		entity := models.MessageEntity{
+			Type:          models.MessageEntityType(cast.ToString(e["type"])),
+			Offset:        cast.ToInt(e["offset"]),
+			Length:        cast.ToInt(e["length"]),
+			URL:           cast.ToString(e["url"]),
+			Language:      cast.ToString(e["language"]),
+			CustomEmojiID: cast.ToString(e["customEmojiId"]),
+		}
+		if user, ok := e["user"].(map[string]interface{}); ok {
+			entity.User = &models.User{ID: cast.ToInt64(user["id"])}
+		}
+		entities = append(entities, entity)
+	}
+	return entities
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/go-telegram/bot/models"
+)
+
+func entity(t models.MessageEntityType, offset, length int) models.MessageEntity {
+	return models.MessageEntity{Type: t, Offset: offset, Length: length}
+}
+
+func TestRenderEntitiesHTML(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		entities []models.MessageEntity
+		want     string
+	}{
+		{
+			name: "plain text is escaped",
+			text: "a < b & c",
+			want: "a &lt; b &amp; c",
+		},
+		{
+			name:     "offsets count UTF-16 code units",
+			text:     "👍 bold 😀 end",
+			entities: []models.MessageEntity{entity(models.MessageEntityTypeBold, 3, 4), entity(models.MessageEntityTypeItalic, 8, 2)},
+			want:     "👍 <b>bold</b> <i>😀</i> end",
+		},
+		{
+			name:     "nested entities",
+			text:     "hello world",
+			entities: []models.MessageEntity{entity(models.MessageEntityTypeItalic, 6, 5), entity(models.MessageEntityTypeBold, 0, 11)},
+			want:     "<b>hello <i>world</i></b>",
+		},
+		{
+			name:     "crossing entity is reopened after its parent",
+			text:     "abcdefgh",
+			entities: []models.MessageEntity{entity(models.MessageEntityTypeBold, 0, 5), entity(models.MessageEntityTypeItalic, 3, 5)},
+			want:     "<b>abc<i>de</i></b><i>fgh</i>",
+		},
+		{
+			name: "entity crossing several parents",
+			text: "abcdefgh",
+			entities: []models.MessageEntity{
+				entity(models.MessageEntityTypeBold, 0, 4),
+				entity(models.MessageEntityTypeUnderline, 1, 2),
+				entity(models.MessageEntityTypeItalic, 2, 6),
+			},
+			want: "<b>a<u>b<i>c</i></u><i>d</i></b><i>efgh</i>",
+		},
+		{
+			name: "links and pre",
+			text: "site code",
+			entities: []models.MessageEntity{
+				{Type: models.MessageEntityTypeTextLink, Offset: 0, Length: 4, URL: "https://example.com/?a=1&b=2"},
+				{Type: models.MessageEntityTypePre, Offset: 5, Length: 4, Language: "go"},
+			},
+			want: `<a href="https://example.com/?a=1&amp;b=2">site</a> <pre><code class="language-go">code</code></pre>`,
+		},
+		{
+			name:     "entities past the end are clamped",
+			text:     "abc",
+			entities: []models.MessageEntity{entity(models.MessageEntityTypeBold, 1, 10), entity(models.MessageEntityTypeItalic, 5, 1)},
+			want:     "a<b>bc</b>",
+		},
+	}
+	for _, tt := range tests {
+		if got := renderEntities(tt.text, tt.entities, htmlRenderer); got != tt.want {
+			t.Errorf("%s:\ngot  %s\nwant %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRenderEntitiesMarkdownV2(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		entities []models.MessageEntity
+		want     string
+	}{
+		{
+			name: "special characters are escaped",
+			text: "1.5 * (2+3) = 7.5!",
+			want: `1\.5 \* \(2\+3\) \= 7\.5\!`,
+		},
+		{
+			name:     "code only escapes backticks and backslashes",
+			text:     "run a_b`c",
+			entities: []models.MessageEntity{entity(models.MessageEntityTypeCode, 4, 5)},
+			want:     "run `a_b\\`c`",
+		},
+		{
+			name:     "italic closing with its underline parent",
+			text:     "text",
+			entities: []models.MessageEntity{entity(models.MessageEntityTypeUnderline, 0, 4), entity(models.MessageEntityTypeItalic, 2, 2)},
+			want:     "__te_xt_\r__",
+		},
+		{
+			name:     "offsets count UTF-16 code units",
+			text:     "😀 hi",
+			entities: []models.MessageEntity{entity(models.MessageEntityTypeBold, 3, 2)},
+			want:     "😀 *hi*",
+		},
+		{
+			name:     "blockquote lines",
+			text:     "one\ntwo",
+			entities: []models.MessageEntity{entity(entityTypeBlockquote, 0, 7)},
+			want:     ">one\n>two",
+		},
+	}
+	for _, tt := range tests {
+		if got := renderEntities(tt.text, tt.entities, markdownV2Renderer); got != tt.want {
+			t.Errorf("%s:\ngot  %q\nwant %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNestEntities(t *testing.T) {
+	got := nestEntities([]models.MessageEntity{
+		entity(models.MessageEntityTypeBold, 0, 5),
+		entity(models.MessageEntityTypeItalic, 3, 5),
+		entity(models.MessageEntityTypeCode, 6, 1),
+	})
+	want := []models.MessageEntity{
+		entity(models.MessageEntityTypeBold, 0, 5),
+		entity(models.MessageEntityTypeItalic, 3, 2),
+		entity(models.MessageEntityTypeItalic, 5, 3),
+		entity(models.MessageEntityTypeCode, 6, 1),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v\nwant %+v", got, want)
+	}
+}
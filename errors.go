@@ -0,0 +1,193 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/go-telegram/bot"
+)
+
+// Stable error kinds exposed to JS as err.kind
+const (
+	errKindBlocked            = "blocked"
+	errKindUserDeactivated    = "user_deactivated"
+	errKindKicked             = "kicked"
+	errKindChatNotFound       = "chat_not_found"
+	errKindMessageNotModified = "message_not_modified"
+	errKindMessageNotFound    = "message_not_found"
+	errKindQueryExpired       = "query_expired"
+	errKindNotEnoughRights    = "not_enough_rights"
+	errKindChatMigrated       = "chat_migrated"
+	errKindTooManyRequests    = "too_many_requests"
+	errKindBadRequest         = "bad_request"
+	errKindUnauthorized       = "unauthorized"
+	errKindForbidden          = "forbidden"
+	errKindNotFound           = "not_found"
+	errKindConflict           = "conflict"
+	errKindServerError        = "server_error"
+	errKindNetwork            = "network"
+	errKindUnknown            = "unknown"
+)
+
+// errorDescriptionKinds maps well-known Bot API error descriptions to kinds
+var errorDescriptionKinds = []struct {
+	substring string
+	kind      string
+}{
+	{"bot was blocked by the user", errKindBlocked},
+	{"user is deactivated", errKindUserDeactivated},
+	{"bot was kicked", errKindKicked},
+	{"bot is not a member", errKindKicked},
+	{"chat not found", errKindChatNotFound},
+	{"peer_id_invalid", errKindChatNotFound},
+	{"message is not modified", errKindMessageNotModified},
+	{"message to edit not found", errKindMessageNotFound},
+	{"message to delete not found", errKindMessageNotFound},
+	{"message not found", errKindMessageNotFound},
+	{"query is too old", errKindQueryExpired},
+	{"not enough rights", errKindNotEnoughRights},
+}
+
+// TelegramError is a Bot API error broken down into its parts
+type TelegramError struct {
+	Code            int
+	Description     string
+	Kind            string
+	RetryAfter      int
+	MigrateToChatID int64
+}
+
+func (e *TelegramError) Error() string {
+	return e.Description
+}
+
+// newTelegramError parses an error returned by the bot library. The library
+// only keeps the description in the message, prefixed by the status text.
+func newTelegramError(err error) *TelegramError {
+	var tgErr *TelegramError
+	if errors.As(err, &tgErr) {
+		return tgErr
+	}
+
+	e := &TelegramError{Description: err.Error()}
+
+	var tooMany *bot.TooManyRequestsError
+	var migrate *bot.MigrateError
+	switch {
+	case errors.As(err, &tooMany):
+		e.Code = 429
+		e.RetryAfter = tooMany.RetryAfter
+		e.Description = trimStatus(tooMany.Message, bot.ErrorTooManyRequests)
+	case errors.As(err, &migrate):
+		e.Code = 400
+		e.MigrateToChatID = int64(migrate.MigrateToChatID)
+		e.Description = strings.TrimPrefix(migrate.Message, bot.ErrorBadRequest.Error()+": ")
+		e.Kind = errKindChatMigrated
+	case errors.Is(err, bot.ErrorBadRequest):
+		e.Code = 400
+		e.Description = trimStatus(e.Description, bot.ErrorBadRequest)
+	case errors.Is(err, bot.ErrorUnauthorized):
+		e.Code = 401
+		e.Description = trimStatus(e.Description, bot.ErrorUnauthorized)
+	case errors.Is(err, bot.ErrorForbidden):
+		e.Code = 403
+		e.Description = trimStatus(e.Description, bot.ErrorForbidden)
+	case errors.Is(err, bot.ErrorNotFound):
+		e.Code = 404
+		e.Description = trimStatus(e.Description, bot.ErrorNotFound)
+	case errors.Is(err, bot.ErrorConflict):
+		e.Code = 409
+		e.Description = trimStatus(e.Description, bot.ErrorConflict)
+	case strings.HasPrefix(e.Description, "error response from telegram"):
+		// "error response from telegram for method X, 502 Bad Gateway"
+		if _, rest, ok := strings.Cut(e.Description, ", "); ok {
+			var code int
+			if _, err := fmt.Sscanf(rest, "%d", &code); err == nil {
+				e.Code = code
+				e.Description = strings.TrimSpace(strings.TrimPrefix(rest, fmt.Sprint(code)))
+			}
+		}
+	case strings.HasPrefix(e.Description, "error do request"),
+		strings.HasPrefix(e.Description, "error read response body"):
+		e.Kind = errKindNetwork
+		// The request URL contains the token, keep only the cause
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			e.Description = strings.Replace(e.Description, urlErr.Error(), urlErr.Err.Error(), 1)
+		}
+	}
+
+	if e.Kind == "" {
+		e.Kind = errorKind(e.Code, e.Description)
+	}
+	return e
+}
+
+// trimStatus removes the library's "<status>, " prefix from a description
+func trimStatus(message string, status error) string {
+	return strings.TrimPrefix(message, status.Error()+", ")
+}
+
+// errorKind returns the kind of an API error from its description, falling
+// back to its status code
+func errorKind(code int, description string) string {
+	lower := strings.ToLower(description)
+	for _, known := range errorDescriptionKinds {
+		if strings.Contains(lower, known.substring) {
+			return known.kind
+		}
+	}
+	switch {
+	case code == 400:
+		return errKindBadRequest
+	case code == 401:
+		return errKindUnauthorized
+	case code == 403:
+		return errKindForbidden
+	case code == 404:
+		return errKindNotFound
+	case code == 409:
+		return errKindConflict
+	case code == 429:
+		return errKindTooManyRequests
+	case code >= 500:
+		return errKindServerError
+	}
+	return errKindUnknown
+}
+
+// apiError converts an error from a Bot API call into a JS exception. The
+// thrown value is an Error with name "TelegramError" and the code,
+// description, kind, retryAfter and migrateToChatId properties.
+func (instance *BotInstance) apiError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if instance.runtime == nil {
+		return err
+	}
+	e := newTelegramError(err)
+	rt := instance.runtime
+
+	obj, jsErr := rt.New(rt.Get("Error"), rt.ToValue(e.Description))
+	if jsErr != nil {
+		return err
+	}
+	obj.Set("name", "TelegramError")
+	obj.Set("code", e.Code)
+	obj.Set("description", e.Description)
+	obj.Set("kind", e.Kind)
+	if e.RetryAfter > 0 {
+		obj.Set("retryAfter", e.RetryAfter)
+	}
+	if e.MigrateToChatID != 0 {
+		obj.Set("migrateToChatId", e.MigrateToChatID)
+	}
+
+	if ex := rt.Try(func() { panic(obj) }); ex != nil {
+		return ex
+	}
+	return err
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-telegram/bot"
+)
+
+// apiCallError makes a real call against a fake Bot API server answering
+// with status and body, so the test sees the library's own error values
+func apiCallError(t *testing.T, status int, body string) error {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		fmt.Fprint(w, body)
+	}))
+	defer server.Close()
+
+	b, err := bot.New("123:secret", bot.WithSkipGetMe(), bot.WithServerURL(server.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = b.SendMessage(context.Background(), &bot.SendMessageParams{ChatID: 1, Text: "hi"})
+	if err == nil {
+		t.Fatal("call succeeded")
+	}
+	return err
+}
+
+func TestNewTelegramErrorKinds(t *testing.T) {
+	tests := []struct {
+		status      int
+		body        string
+		code        int
+		kind        string
+		description string
+	}{
+		{403, `{"ok":false,"error_code":403,"description":"Forbidden: bot was blocked by the user"}`, 403, errKindBlocked, "Forbidden: bot was blocked by the user"},
+		{403, `{"ok":false,"error_code":403,"description":"Forbidden: user is deactivated"}`, 403, errKindUserDeactivated, "Forbidden: user is deactivated"},
+		{400, `{"ok":false,"error_code":400,"description":"Bad Request: chat not found"}`, 400, errKindChatNotFound, "Bad Request: chat not found"},
+		{400, `{"ok":false,"error_code":400,"description":"Bad Request: message is not modified"}`, 400, errKindMessageNotModified, "Bad Request: message is not modified"},
+		{400, `{"ok":false,"error_code":400,"description":"Bad Request: query is too old and response timeout expired"}`, 400, errKindQueryExpired, "Bad Request: query is too old and response timeout expired"},
+		{400, `{"ok":false,"error_code":400,"description":"Bad Request: can't parse entities"}`, 400, errKindBadRequest, "Bad Request: can't parse entities"},
+		{401, `{"ok":false,"error_code":401,"description":"Unauthorized"}`, 401, errKindUnauthorized, "Unauthorized"},
+		{409, `{"ok":false,"error_code":409,"description":"Conflict: terminated by other getUpdates request"}`, 409, errKindConflict, "Conflict: terminated by other getUpdates request"},
+		{502, `{"ok":false,"error_code":502,"description":"Bad Gateway"}`, 502, errKindServerError, "Bad Gateway"},
+	}
+	for _, tt := range tests {
+		e := newTelegramError(apiCallError(t, tt.status, tt.body))
+		if e.Code != tt.code || e.Kind != tt.kind || e.Description != tt.description {
+			t.Errorf("%s: got code %d, kind %q, description %q", tt.body, e.Code, e.Kind, e.Description)
+		}
+	}
+}
+
+func TestNewTelegramErrorParameters(t *testing.T) {
+	e := newTelegramError(apiCallError(t, 429, `{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 7","parameters":{"retry_after":7}}`))
+	if e.Code != 429 || e.Kind != errKindTooManyRequests || e.RetryAfter != 7 {
+		t.Errorf("rate limit: got %+v", *e)
+	}
+
+	e = newTelegramError(apiCallError(t, 400, `{"ok":false,"error_code":400,"description":"Bad Request: group chat was upgraded to a supergroup chat","parameters":{"migrate_to_chat_id":-1001234}}`))
+	if e.Code != 400 || e.Kind != errKindChatMigrated || e.MigrateToChatID != -1001234 {
+		t.Errorf("migration: got %+v", *e)
+	}
+}
+
+func TestNewTelegramErrorNetworkHidesToken(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close() // Connections are refused from now on
+
+	b, err := bot.New("123:secret", bot.WithSkipGetMe(), bot.WithServerURL(server.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = b.SendMessage(context.Background(), &bot.SendMessageParams{ChatID: 1, Text: "hi"})
+	if err == nil {
+		t.Fatal("call succeeded")
+	}
+
+	e := newTelegramError(err)
+	if e.Kind != errKindNetwork {
+		t.Errorf("kind %q, want %q", e.Kind, errKindNetwork)
+	}
+	if strings.Contains(e.Description, "secret") || strings.Contains(e.Description, server.URL) {
+		t.Errorf("description leaks the request URL: %s", e.Description)
+	}
+	if !strings.Contains(e.Description, "sendMessage") {
+		t.Errorf("description lost the method: %s", e.Description)
+	}
+}
+
+func TestNewTelegramErrorKeepsParsedErrors(t *testing.T) {
+	original := &TelegramError{Code: 403, Kind: errKindBlocked, Description: "blocked"}
+	if e := newTelegramError(fmt.Errorf("wrapped: %w", original)); e != original {
+		t.Errorf("got %+v, want the wrapped TelegramError", *e)
+	}
+	if e := newTelegramError(errors.New("something else")); e.Kind != errKindUnknown {
+		t.Errorf("kind %q, want %q", e.Kind, errKindUnknown)
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/dop251/goja"
+)
+
+const defaultQueueSize = 256
+
+// Job states; a queued job is either started by the loop or abandoned by
+// its caller, whichever comes first
+const (
+	jobQueued int32 = iota
+	jobStarted
+	jobAbandoned
+)
+
+var errLoopStopped = errors.New("event loop stopped")
+
+// jsJob is a unit of work scheduled on a jsLoop
+type jsJob struct {
+	ctx   context.Context
+	fn    func()
+	err   error
+	state atomic.Int32
+	done  chan struct{}
+}
+
+// jsLoop serializes every call into a goja.Runtime onto a single goroutine.
+// goja runtimes are not goroutine-safe, so all bots sharing a runtime push
+// their handler invocations through one bounded job queue.
+type jsLoop struct {
+	runtime *goja.Runtime
+	jobs    chan *jsJob
+	stopCh  chan struct{}
+	once    sync.Once
+	// goroutine is the ID of the loop goroutine
+	goroutine atomic.Uint64
+}
+
+func newJSLoop(runtime *goja.Runtime, queueSize int) *jsLoop {
+	if queueSize <= 0 {
+		queueSize = defaultQueueSize
+	}
+	l := &jsLoop{
+		runtime: runtime,
+		jobs:    make(chan *jsJob, queueSize),
+		stopCh:  make(chan struct{}),
+	}
+	go l.loop()
+	return l
+}
+
+func (l *jsLoop) loop() {
+	l.goroutine.Store(goroutineID())
+	for {
+		select {
+		case <-l.stopCh:
+			return
+		case job := <-l.jobs:
+			if !job.state.CompareAndSwap(jobQueued, jobStarted) {
+				continue // Its caller gave up waiting
+			}
+			if job.err = job.ctx.Err(); job.err == nil {
+				l.exec(job.fn)
+			}
+			close(job.done)
+		}
+	}
+}
+
+func (l *jsLoop) exec(fn func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Printf("[ERROR] Event loop job panic: %v\n%s\n", r, debug.Stack())
+		}
+	}()
+	fn()
+}
+
+// Run schedules fn on the loop and waits until it has finished. It blocks
+// while the queue is full, which pushes backpressure onto the update source,
+// and gives up if ctx is cancelled before fn starts.
+//
+// A job calling back into Run (e.g. startBot from inside a handler) already
+// owns the runtime, so fn runs inline instead of waiting behind itself.
+func (l *jsLoop) Run(ctx context.Context, fn func()) error {
+	if l.onLoop() {
+		fn()
+		return nil
+	}
+
+	job := &jsJob{ctx: ctx, fn: fn, done: make(chan struct{})}
+	select {
+	case l.jobs <- job:
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-l.stopCh:
+		return errLoopStopped
+	}
+
+	select {
+	case <-job.done:
+		return job.err
+	case <-ctx.Done():
+	case <-l.stopCh:
+	}
+	// Drop the job unless it is already running; then fn may still use the
+	// caller's state, so wait for it to return
+	if job.state.CompareAndSwap(jobQueued, jobAbandoned) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return errLoopStopped
+	}
+	<-job.done
+	return job.err
+}
+
+// onLoop reports whether the caller runs on the loop goroutine, i.e. inside
+// a job
+func (l *jsLoop) onLoop() bool {
+	return goroutineID() == l.goroutine.Load()
+}
+
+// Stop terminates the loop goroutine; pending jobs are discarded
+func (l *jsLoop) Stop() {
+	l.once.Do(func() {
+		close(l.stopCh)
+	})
+}
+
+// goroutineID returns the ID of the calling goroutine, taken from the
+// "goroutine 42 [running]:" header of its stack trace
+func goroutineID() uint64 {
+	buf := make([]byte, 64)
+	buf = bytes.TrimPrefix(buf[:runtime.Stack(buf, false)], []byte("goroutine "))
+	if i := bytes.IndexByte(buf, ' '); i >= 0 {
+		buf = buf[:i]
+	}
+	id, _ := strconv.ParseUint(string(buf), 10, 64)
+	return id
+}
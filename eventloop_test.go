@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+func TestJSLoopJobsNeverOverlap(t *testing.T) {
+	loop := newJSLoop(goja.New(), 4)
+	defer loop.Stop()
+
+	var running, overlaps atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				err := loop.Run(context.Background(), func() {
+					if running.Add(1) > 1 {
+						overlaps.Add(1)
+					}
+					time.Sleep(10 * time.Microsecond)
+					running.Add(-1)
+				})
+				if err != nil {
+					t.Errorf("Run: %v", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := overlaps.Load(); n > 0 {
+		t.Fatalf("%d jobs ran while another job was executing", n)
+	}
+}
+
+func TestJSLoopReentrantRun(t *testing.T) {
+	loop := newJSLoop(goja.New(), 4)
+	defer loop.Stop()
+
+	done := make(chan error, 1)
+	go func() {
+		inner := false
+		err := loop.Run(context.Background(), func() {
+			if err := loop.Run(context.Background(), func() { inner = true }); err != nil {
+				done <- err
+			}
+		})
+		if err == nil && !inner {
+			err = errors.New("nested job did not run")
+		}
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("nested Run deadlocked")
+	}
+}
+
+func TestJSLoopCancelledBeforeStart(t *testing.T) {
+	loop := newJSLoop(goja.New(), 4)
+	defer loop.Stop()
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	go loop.Run(context.Background(), func() {
+		close(started)
+		<-release
+	})
+	<-started
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ran := false
+	result := make(chan error, 1)
+	go func() {
+		result <- loop.Run(ctx, func() { ran = true })
+	}()
+	cancel()
+
+	if err := <-result; !errors.Is(err, context.Canceled) {
+		t.Fatalf("Run returned %v, want context.Canceled", err)
+	}
+	close(release)
+
+	// Wait for the loop to drain the abandoned job
+	if err := loop.Run(context.Background(), func() {}); err != nil {
+		t.Fatal(err)
+	}
+	if ran {
+		t.Fatal("cancelled job ran")
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/spf13/cast"
+
+	"github.com/levskiy0/m3m/pkg/plugin"
+)
+
+const (
+	downloadsDir = "downloads"
+	// Downloads of unknown size are assumed to be as large as the cloud Bot
+	// API allows
+	maxCloudDownloadSize = 20 << 20
+	// minDownloadSpeed is the slowest transfer, in bytes per second, the
+	// default download timeout allows for
+	minDownloadSpeed = 256 << 10
+	// maxLoopDownload is the longest a download from a handler may hold up
+	// the event loop
+	maxLoopDownload = 10 * time.Second
+)
+
+// createDownloadFile creates bot.downloadFile(fileId, destPath?, options?)
+func (instance *BotInstance) createDownloadFile() func(string, string, map[string]interface{}) (map[string]interface{}, error) {
+	return func(fileID string, destPath string, options map[string]interface{}) (map[string]interface{}, error) {
+		return instance.downloadFile(fileID, destPath, options)
+	}
+}
+
+// downloadFile saves a file sent to the bot under the storage path. The
+// download URL contains the bot token, so it never leaves Go. destPath is
+// resolved against the storage path; when it is empty or ends with "/" the
+// file is named after its unique ID. options.maxSize caps the size in bytes
+// and options.timeout the duration in seconds, which is at most
+// maxLoopDownload on the event loop.
+func (instance *BotInstance) downloadFile(fileID string, destPath string, options map[string]interface{}) (map[string]interface{}, error) {
+	if instance.storagePath == "" {
+		return nil, fmt.Errorf("downloadFile requires storage_path")
+	}
+	maxSize := cast.ToInt64(options["maxSize"])
+	timeout := time.Duration(cast.ToFloat64(options["timeout"]) * float64(time.Second))
+
+	file, err := instance.bot.GetFile(instance.ctx, &bot.GetFileParams{FileID: fileID})
+	if err != nil {
+		return nil, instance.apiError(err)
+	}
+	if maxSize > 0 && file.FileSize > maxSize {
+		return nil, fmt.Errorf("file is %d bytes, larger than maxSize %d", file.FileSize, maxSize)
+	}
+
+	if destPath == "" {
+		destPath = downloadsDir + "/"
+	}
+	if strings.HasSuffix(destPath, "/") {
+		destPath += file.FileUniqueID + filepath.Ext(file.FilePath)
+	}
+	destPath = plugin.MustResolvePath(instance.storagePath, destPath)
+
+	// Downloads run on the event loop, so they must not hang forever
+	if timeout <= 0 {
+		timeout = instance.downloadTimeout(file.FileSize, maxSize)
+	}
+	// Nor may they stall every bot on the runtime for long
+	if timeout > maxLoopDownload && instance.loop.onLoop() {
+		timeout = maxLoopDownload
+	}
+	ctx, cancel := context.WithTimeout(instance.ctx, timeout)
+	defer cancel()
+
+	src, contentType, err := instance.openFile(ctx, file)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	size, err := writeFileLimited(destPath, src, maxSize)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("download timed out after %v", timeout)
+		}
+		return nil, err
+	}
+
+	mimeType := mime.TypeByExtension(filepath.Ext(destPath))
+	if mimeType == "" && contentType != "" && contentType != "application/octet-stream" {
+		mimeType = contentType
+	}
+	if mimeType == "" {
+		mimeType = sniffContentType(destPath)
+	}
+
+	return map[string]interface{}{
+		"path":         destPath,
+		"size":         size,
+		"mimeType":     mimeType,
+		"fileId":       file.FileID,
+		"fileUniqueId": file.FileUniqueID,
+	}, nil
+}
+
+// downloadTimeout is the default time limit of a download: the request
+// timeout plus the time a size bytes transfer takes at minDownloadSpeed
+func (instance *BotInstance) downloadTimeout(size, maxSize int64) time.Duration {
+	if size <= 0 {
+		size = maxSize
+	}
+	if size <= 0 {
+		size = maxCloudDownloadSize
+	}
+	return instance.httpClient.Timeout + time.Duration(size/minDownloadSpeed+1)*time.Second
+}
+
+// openFile opens the contents of a file: straight from disk on a local Bot
+// API server, otherwise from the file download endpoint. ctx bounds the
+// whole download, including reading the body.
+func (instance *BotInstance) openFile(ctx context.Context, file *models.File) (io.ReadCloser, string, error) {
+	if instance.localMode && filepath.IsAbs(file.FilePath) {
+		f, err := os.Open(file.FilePath)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to open file: %w", err)
+		}
+		return f, "", nil
+	}
+
+	endpoint := instance.apiURL + "/file/bot" + instance.token + "/" + file.FilePath
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to download file")
+	}
+
+	// Downloads may take longer than API requests, so the context bounds
+	// them instead of the client timeout
+	client := &http.Client{Transport: instance.httpClient.Transport}
+	resp, err := client.Do(req)
+	if err != nil {
+		// The URL contains the token, keep it out of the error
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return nil, "", fmt.Errorf("failed to download file: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, "", fmt.Errorf("failed to download file: %s", resp.Status)
+	}
+	return resp.Body, resp.Header.Get("Content-Type"), nil
+}
+
+// writeFileLimited streams src into path, failing when more than maxSize
+// bytes arrive (0 means no limit). A partial file is never left behind.
+func writeFileLimited(path string, src io.Reader, maxSize int64) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return 0, fmt.Errorf("failed to create directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	reader := src
+	if maxSize > 0 {
+		reader = io.LimitReader(src, maxSize+1)
+	}
+	size, err := io.Copy(tmp, reader)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to save file: %w", err)
+	}
+	if maxSize > 0 && size > maxSize {
+		return 0, fmt.Errorf("file is larger than maxSize %d", maxSize)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return 0, fmt.Errorf("failed to save file: %w", err)
+	}
+	return size, nil
+}
+
+// sniffContentType guesses the MIME type from the first bytes of a file
+func sniffContentType(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return "application/octet-stream"
+	}
+	defer f.Close()
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(f, head)
+	return http.DetectContentType(head[:n])
+}
+
+// createDownloadPhoto creates ctx.downloadPhoto(destPath?, options?), which
+// downloads the largest size of the photo in the current message
+func (uctx *UpdateContext) createDownloadPhoto() func(string, map[string]interface{}) (map[string]interface{}, error) {
+	return func(destPath string, options map[string]interface{}) (map[string]interface{}, error) {
+		msg := uctx.getMessage()
+		if msg == nil || len(msg.Photo) == 0 {
+			return nil, fmt.Errorf("message has no photo")
+		}
+		largest := msg.Photo[0]
+		for _, size := range msg.Photo[1:] {
+			if size.Width*size.Height > largest.Width*largest.Height {
+				largest = size
+			}
+		}
+		return uctx.instance.downloadFile(largest.FileID, destPath, options)
+	}
+}
@@ -0,0 +1,390 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/spf13/cast"
+)
+
+const (
+	defaultGlobalRate   = 30 // messages per second
+	defaultChatRate     = 1  // messages per second in one chat
+	defaultGroupRate    = 20 // messages per minute in one group
+	chatBurst           = 3
+	maxSendRetries      = 3
+	maxRetryAfter       = 60 * time.Second
+	maxLoopWait         = time.Second // longest a handler's send may hold up the event loop
+	retryBaseDelay      = 500 * time.Millisecond
+	floodSweepPeriod    = 5 * time.Minute
+	chatLimitsIdleAfter = 2 * time.Minute
+)
+
+// floodLimits holds the outgoing message rates; zero disables a limit
+type floodLimits struct {
+	global float64 // per second, across all chats
+	chat   float64 // per second, per chat
+	group  float64 // per minute, per group or channel
+}
+
+// parseFloodLimits converts the startBot "rateLimit" option. false disables
+// rate limiting; retries on 429 and transient errors stay enabled.
+func parseFloodLimits(value interface{}) (floodLimits, error) {
+	limits := floodLimits{
+		global: defaultGlobalRate,
+		chat:   defaultChatRate,
+		group:  defaultGroupRate,
+	}
+	switch v := value.(type) {
+	case nil:
+	case bool:
+		if !v {
+			return floodLimits{}, nil
+		}
+	case map[string]interface{}:
+		if global, ok := v["global"]; ok {
+			limits.global = cast.ToFloat64(global)
+		}
+		if chat, ok := v["perChat"]; ok {
+			limits.chat = cast.ToFloat64(chat)
+		}
+		if group, ok := v["perGroup"]; ok {
+			limits.group = cast.ToFloat64(group)
+		}
+	default:
+		return limits, fmt.Errorf("rateLimit option must be a boolean or an object")
+	}
+	return limits, nil
+}
+
+// tokenBucket is a simple rate limiter. Reservations may drive the balance
+// negative, which makes later callers wait their turn.
+type tokenBucket struct {
+	tokens float64
+	rate   float64 // tokens per second
+	burst  float64
+	last   time.Time
+}
+
+func newTokenBucket(rate, burst float64) *tokenBucket {
+	return &tokenBucket{tokens: burst, rate: rate, burst: burst, last: time.Now()}
+}
+
+// reserve takes a token and returns how long the caller must wait for it
+func (b *tokenBucket) reserve(now time.Time) time.Duration {
+	if b == nil {
+		return 0
+	}
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// release gives back a token taken by reserve that was not used
+func (b *tokenBucket) release() {
+	if b != nil {
+		b.tokens++
+	}
+}
+
+// chatLimits tracks the rate of one chat
+type chatLimits struct {
+	second      *tokenBucket
+	minute      *tokenBucket
+	pausedUntil time.Time
+	lastUsed    time.Time
+}
+
+// floodControl is the HTTP client of the bot. It schedules outgoing
+// messages within Telegram's rate limits and retries requests that failed
+// with 429 Too Many Requests, a 5xx status or a network error. Working at
+// the HTTP level lets it replay file uploads and covers every API method.
+//
+// Requests made on the event loop (API calls from handlers) never sleep for
+// long, since that would stall every bot on the runtime: when a message would
+// have to wait longer than maxLoopWait they fail with 429 right away.
+type floodControl struct {
+	client    bot.HttpClient
+	limits    floodLimits
+	onLoop    func() bool
+	mu        sync.Mutex
+	global    *tokenBucket
+	chats     map[string]*chatLimits
+	lastSweep time.Time
+}
+
+func newFloodControl(client bot.HttpClient, limits floodLimits, onLoop func() bool) *floodControl {
+	f := &floodControl{
+		client:    client,
+		limits:    limits,
+		onLoop:    onLoop,
+		chats:     make(map[string]*chatLimits),
+		lastSweep: time.Now(),
+	}
+	if limits.global > 0 {
+		f.global = newTokenBucket(limits.global, limits.global)
+	}
+	return f
+}
+
+// Do implements bot.HttpClient. Attempts are replayed from req.GetBody,
+// which the library's in-memory request bodies provide, so uploads are never
+// copied; a request without it is sent once and not paced per chat.
+func (f *floodControl) Do(req *http.Request) (*http.Response, error) {
+	replayable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+
+	chatID := ""
+	if isMessageMethod(path.Base(req.URL.Path)) && req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			chatID = formValue(req.Header.Get("Content-Type"), body, "chat_id")
+			body.Close()
+		}
+	}
+	ctx := req.Context()
+
+	// On the event loop only short waits are allowed
+	maxWait := time.Duration(0)
+	if f.onLoop != nil && f.onLoop() {
+		maxWait = maxLoopWait
+	}
+	canWait := func(d time.Duration) bool {
+		return maxWait == 0 || d <= maxWait
+	}
+
+	for attempt := 0; ; attempt++ {
+		if chatID != "" {
+			delay, ok := f.reserve(chatID, maxWait)
+			if !ok {
+				return tooManyRequests(req, delay), nil
+			}
+			if err := sleepContext(ctx, delay); err != nil {
+				return nil, err
+			}
+		}
+
+		r := req
+		if attempt > 0 {
+			r = req.Clone(ctx)
+			if req.GetBody != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					return nil, err
+				}
+				r.Body = body
+			}
+		}
+
+		resp, err := f.client.Do(r)
+		retry := replayable && attempt < maxSendRetries && ctx.Err() == nil
+		delay := retryDelay(attempt)
+		switch {
+		case err != nil:
+			if !retry || !canWait(delay) {
+				return nil, err
+			}
+		case resp.StatusCode == http.StatusTooManyRequests:
+			data, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			resp.Body = io.NopCloser(bytes.NewReader(data))
+
+			retryAfter := parseRetryAfter(data)
+			f.pause(chatID, retryAfter)
+			if !retry || retryAfter > maxRetryAfter || !canWait(retryAfter) {
+				return resp, nil
+			}
+			if chatID == "" {
+				if err := sleepContext(ctx, retryAfter); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		case resp.StatusCode >= http.StatusInternalServerError:
+			if !retry || !canWait(delay) {
+				return resp, nil
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		default:
+			return resp, nil
+		}
+
+		if err := sleepContext(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// reserve takes a slot for a message to chatID and returns how long the
+// caller must wait for it. A slot further away than maxWait (0 means no
+// limit) is not taken; reserve returns the delay and false instead.
+func (f *floodControl) reserve(chatID string, maxWait time.Duration) (time.Duration, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := time.Now()
+	cl := f.chatLimits(chatID, now)
+	buckets := []*tokenBucket{f.global, cl.second, cl.minute}
+	delay := cl.pausedUntil.Sub(now)
+	for _, b := range buckets {
+		if d := b.reserve(now); d > delay {
+			delay = d
+		}
+	}
+	if maxWait > 0 && delay > maxWait {
+		for _, b := range buckets {
+			b.release()
+		}
+		return delay, false
+	}
+	return delay, true
+}
+
+// tooManyRequests builds the response Telegram sends when rate limited, so a
+// message held back by flood control fails the same way and JS sees a
+// too_many_requests error with retryAfter
+func tooManyRequests(req *http.Request, retryAfter time.Duration) *http.Response {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	body := fmt.Sprintf(`{"ok":false,"error_code":429,"description":"Too Many Requests: retry after %d","parameters":{"retry_after":%d}}`, seconds, seconds)
+	return &http.Response{
+		Status:        "429 Too Many Requests",
+		StatusCode:    http.StatusTooManyRequests,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"application/json"}},
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+// pause holds back messages to chatID after Telegram asked to slow down
+func (f *floodControl) pause(chatID string, d time.Duration) {
+	if chatID == "" {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := time.Now()
+	cl := f.chatLimits(chatID, now)
+	if until := now.Add(d); until.After(cl.pausedUntil) {
+		cl.pausedUntil = until
+	}
+}
+
+// chatLimits returns the limits of chatID. Must be called with f.mu held.
+func (f *floodControl) chatLimits(chatID string, now time.Time) *chatLimits {
+	if now.Sub(f.lastSweep) >= floodSweepPeriod {
+		for id, cl := range f.chats {
+			if now.Sub(cl.lastUsed) >= chatLimitsIdleAfter && now.After(cl.pausedUntil) {
+				delete(f.chats, id)
+			}
+		}
+		f.lastSweep = now
+	}
+
+	cl, ok := f.chats[chatID]
+	if !ok {
+		cl = &chatLimits{}
+		if f.limits.chat > 0 {
+			cl.second = newTokenBucket(f.limits.chat, chatBurst)
+		}
+		// Groups and channels have negative IDs or a public @username
+		isGroup := strings.HasPrefix(chatID, "-") || strings.HasPrefix(chatID, "@")
+		if isGroup && f.limits.group > 0 {
+			cl.minute = newTokenBucket(f.limits.group/60, f.limits.group)
+		}
+		f.chats[chatID] = cl
+	}
+	cl.lastUsed = now
+	return cl
+}
+
+// isMessageMethod reports whether an API method posts or edits a message and
+// therefore counts against the per-chat limits
+func isMessageMethod(method string) bool {
+	switch {
+	case method == "sendChatAction":
+		return false
+	case strings.HasPrefix(method, "send"),
+		strings.HasPrefix(method, "copyMessage"),
+		strings.HasPrefix(method, "forwardMessage"),
+		strings.HasPrefix(method, "editMessage"):
+		return true
+	}
+	return false
+}
+
+// formValue returns a field of a multipart request body. It stops at the
+// field, so file parts sent after it are never read.
+func formValue(contentType string, body io.Reader, name string) string {
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil || params["boundary"] == "" {
+		return ""
+	}
+	reader := multipart.NewReader(body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err != nil {
+			return ""
+		}
+		if part.FormName() == name {
+			value, _ := io.ReadAll(io.LimitReader(part, 256))
+			return string(value)
+		}
+	}
+}
+
+// parseRetryAfter reads parameters.retry_after from an error response
+func parseRetryAfter(data []byte) time.Duration {
+	var resp struct {
+		Parameters struct {
+			RetryAfter int `json:"retry_after"`
+		} `json:"parameters"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil || resp.Parameters.RetryAfter <= 0 {
+		return time.Second
+	}
+	return time.Duration(resp.Parameters.RetryAfter) * time.Second
+}
+
+// retryDelay is an exponential backoff with jitter: between half and all of
+// retryBaseDelay * 2^attempt
+func retryDelay(attempt int) time.Duration {
+	max := retryBaseDelay << attempt
+	return max/2 + time.Duration(rand.Int63n(int64(max/2)))
+}
+
+// sleepContext waits for d or until ctx is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
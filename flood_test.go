@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Now()
+	b := newTokenBucket(1, 3)
+	b.last = now
+
+	for i := 0; i < 3; i++ {
+		if d := b.reserve(now); d != 0 {
+			t.Fatalf("burst token %d: wait %v, want 0", i, d)
+		}
+	}
+	if d := b.reserve(now); d != time.Second {
+		t.Fatalf("first token past the burst: wait %v, want 1s", d)
+	}
+	if d := b.reserve(now); d != 2*time.Second {
+		t.Fatalf("second token past the burst: wait %v, want 2s", d)
+	}
+
+	// Tokens refill at the rate, but never beyond the burst
+	if d := b.reserve(now.Add(time.Hour)); d != 0 {
+		t.Fatalf("after an hour: wait %v, want 0", d)
+	}
+	if b.tokens != 2 {
+		t.Fatalf("%v tokens left, want burst minus one", b.tokens)
+	}
+}
+
+func TestTokenBucketRelease(t *testing.T) {
+	now := time.Now()
+	b := newTokenBucket(1, 1)
+	b.last = now
+	b.reserve(now)
+	b.reserve(now)
+	b.release()
+	if d := b.reserve(now); d != time.Second {
+		t.Fatalf("wait %v after release, want 1s", d)
+	}
+
+	var none *tokenBucket
+	none.release()
+	if d := none.reserve(now); d != 0 {
+		t.Fatalf("disabled bucket: wait %v, want 0", d)
+	}
+}
+
+// countingClient answers every request with the same status and body
+type countingClient struct {
+	calls  atomic.Int32
+	status int
+	body   string
+}
+
+func (c *countingClient) Do(req *http.Request) (*http.Response, error) {
+	c.calls.Add(1)
+	return &http.Response{
+		StatusCode: c.status,
+		Body:       io.NopCloser(strings.NewReader(c.body)),
+		Request:    req,
+	}, nil
+}
+
+func newSendRequest(t *testing.T, chatID string) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	w.WriteField("chat_id", chatID)
+	w.WriteField("text", "hi")
+	w.Close()
+	req, err := http.NewRequest(http.MethodPost, "https://api.telegram.org/bot123:test/sendMessage", &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	return req
+}
+
+func TestFloodControlFailsFastOnLoop(t *testing.T) {
+	client := &countingClient{status: http.StatusOK, body: `{"ok":true,"result":true}`}
+	f := newFloodControl(client, floodLimits{group: 2}, func() bool { return true })
+
+	for i := 0; i < 2; i++ {
+		resp, err := f.Do(newSendRequest(t, "-100"))
+		if err != nil || resp.StatusCode != http.StatusOK {
+			t.Fatalf("send %d within the limit: %v %v", i, resp, err)
+		}
+	}
+
+	// The next slot is 30 seconds away: fail instead of blocking the loop
+	for i := 0; i < 2; i++ {
+		start := time.Now()
+		resp, err := f.Do(newSendRequest(t, "-100"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusTooManyRequests {
+			t.Fatalf("status %d, want 429", resp.StatusCode)
+		}
+		data, _ := io.ReadAll(resp.Body)
+		// The slot is given back, so repeated attempts do not push it further
+		if retryAfter := parseRetryAfter(data); retryAfter != 30*time.Second {
+			t.Fatalf("retry_after %v, want 30s", retryAfter)
+		}
+		if elapsed := time.Since(start); elapsed > maxLoopWait {
+			t.Fatalf("send waited %v on the loop", elapsed)
+		}
+	}
+	if n := client.calls.Load(); n != 2 {
+		t.Fatalf("%d requests reached Telegram, want 2", n)
+	}
+}
+
+func TestFloodControlDoesNotSleepOnRetryAfterOnLoop(t *testing.T) {
+	client := &countingClient{
+		status: http.StatusTooManyRequests,
+		body:   `{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 5","parameters":{"retry_after":5}}`,
+	}
+	f := newFloodControl(client, floodLimits{}, func() bool { return true })
+
+	start := time.Now()
+	resp, err := f.Do(newSendRequest(t, "42"))
+	if err != nil || resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("got %v %v, want the 429 response", resp, err)
+	}
+	// The chat stays paused, so the next send fails without a request
+	resp, err = f.Do(newSendRequest(t, "42"))
+	if err != nil || resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("got %v %v, want 429 while paused", resp, err)
+	}
+	if elapsed := time.Since(start); elapsed > maxLoopWait {
+		t.Fatalf("sends waited %v on the loop", elapsed)
+	}
+	if n := client.calls.Load(); n != 1 {
+		t.Fatalf("%d requests reached Telegram, want 1", n)
+	}
+}
+
+func TestIsMessageMethod(t *testing.T) {
+	for method, want := range map[string]bool{
+		"sendMessage":                     true,
+		"sendPhoto":                       true,
+		"copyMessages":                    true,
+		"forwardMessage":                  true,
+		"editMessageText":                 true,
+		"sendChatAction":                  false,
+		"getUpdates":                      false,
+		"answerCallbackQuery":             false,
+		"deleteMessage":                   false,
+		"setChatAdministratorCustomTitle": false,
+	} {
+		if got := isMessageMethod(method); got != want {
+			t.Errorf("isMessageMethod(%q) = %v, want %v", method, got, want)
+		}
+	}
+}
+
+// recordingClient fails the first request with 502 and records every body
+type recordingClient struct {
+	bodies []string
+}
+
+func (c *recordingClient) Do(req *http.Request) (*http.Response, error) {
+	data, _ := io.ReadAll(req.Body)
+	c.bodies = append(c.bodies, string(data))
+	status := http.StatusOK
+	if len(c.bodies) == 1 {
+		status = http.StatusBadGateway
+	}
+	return &http.Response{
+		StatusCode: status,
+		Body:       io.NopCloser(strings.NewReader(`{"ok": true, "result": true}`)),
+		Request:    req,
+	}, nil
+}
+
+func TestFloodControlReplaysBody(t *testing.T) {
+	client := &recordingClient{}
+	f := newFloodControl(client, floodLimits{}, nil)
+
+	req := newSendRequest(t, "42")
+	want, _ := io.ReadAll(mustGetBody(t, req))
+	resp, err := f.Do(req)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("got %v %v, want 200 after a retry", resp, err)
+	}
+	if len(client.bodies) != 2 {
+		t.Fatalf("%d attempts, want 2", len(client.bodies))
+	}
+	for i, body := range client.bodies {
+		if body != string(want) {
+			t.Fatalf("attempt %d sent %q, want %q", i, body, want)
+		}
+	}
+}
+
+func TestFloodControlSendsUnreplayableBodyOnce(t *testing.T) {
+	client := &recordingClient{}
+	f := newFloodControl(client, floodLimits{}, nil)
+
+	req := newSendRequest(t, "42")
+	req.Body = io.NopCloser(mustGetBody(t, req))
+	req.GetBody = nil
+	resp, err := f.Do(req)
+	if err != nil || resp.StatusCode != http.StatusBadGateway {
+		t.Fatalf("got %v %v, want the 502 response", resp, err)
+	}
+	if len(client.bodies) != 1 {
+		t.Fatalf("%d attempts, want 1 for a body that cannot be replayed", len(client.bodies))
+	}
+}
+
+func TestFormValueStopsAtField(t *testing.T) {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	w.WriteField("chat_id", "-100")
+	part, _ := w.CreateFormFile("document", "big.bin")
+	part.Write(bytes.Repeat([]byte{0}, 1<<20))
+	w.Close()
+
+	reader := bytes.NewReader(body.Bytes())
+	if got := formValue(w.FormDataContentType(), reader, "chat_id"); got != "-100" {
+		t.Fatalf("chat_id %q, want -100", got)
+	}
+	if read := reader.Size() - int64(reader.Len()); read > 64<<10 {
+		t.Fatalf("read %d bytes of the body to find chat_id", read)
+	}
+}
+
+func mustGetBody(t *testing.T, req *http.Request) io.ReadCloser {
+	t.Helper()
+	body, err := req.GetBody()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
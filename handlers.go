@@ -10,105 +10,204 @@ import (
 	"github.com/go-telegram/bot/models"
 )
 
-// handleUpdate processes incoming updates
+// handleUpdate processes incoming updates. The library calls it in arrival
+// order; updates are queued into per-chat lanes so that one chat is handled
+// strictly sequentially while other chats keep flowing.
 func (instance *BotInstance) handleUpdate(ctx context.Context, b *bot.Bot, update *models.Update) {
+	// Skip updates that were already handled before a restart
+	if !instance.tracker.begin(update.ID) {
+		return
+	}
+	if err := instance.lanes.push(instance.ctx, update); err != nil {
+		fmt.Printf("[ERROR] Update %d dropped: %v\n", update.ID, err)
+	}
+}
+
+// processUpdate runs routing and handler execution for a single update on the
+// runtime's event loop. Called from the update's lane.
+func (instance *BotInstance) processUpdate(update *models.Update) {
 	uctx := &UpdateContext{
 		instance: instance,
 		update:   update,
 		runtime:  instance.runtime,
 	}
 
-	// Handle callback queries
-	if update.CallbackQuery != nil {
-		if handler, ok := instance.callbacks[update.CallbackQuery.Data]; ok {
-			instance.callHandler(handler, uctx)
-			return
-		}
-		// Try prefix match for callbacks with data
-		for pattern, handler := range instance.callbacks {
-			if len(pattern) > 0 && pattern[len(pattern)-1] == '*' {
-				prefix := pattern[:len(pattern)-1]
-				if len(update.CallbackQuery.Data) >= len(prefix) && update.CallbackQuery.Data[:len(prefix)] == prefix {
-					instance.callHandler(handler, uctx)
-					return
-				}
-			}
-		}
-		if instance.defaultHandler != nil {
-			instance.callHandler(instance.defaultHandler, uctx)
-		}
+	err := instance.waitReady()
+	if err == nil {
+		err = instance.loop.Run(instance.ctx, func() {
+			instance.dispatchUpdate(uctx)
+		})
+	}
+	if err != nil {
+		// Not committed, so the update is received again after a restart
+		fmt.Printf("[ERROR] Update %d dropped: %v\n", update.ID, err)
 		return
 	}
+	instance.tracker.finish(update.ID)
+}
 
-	// Handle messages
-	if update.Message != nil {
-		text := update.Message.Text
+// dispatchUpdate routes an update to the matching handler and runs it through
+// the middleware chain. Must run on the event loop.
+func (instance *BotInstance) dispatchUpdate(uctx *UpdateContext) {
+	// Chats inside a scene are handled by the current scene step
+	if handler, ok := instance.sceneHandler(uctx); ok {
+		instance.callHandler(handler, uctx)
+		instance.afterScene(uctx)
+		return
+	}
 
-		// Try exact match first
-		if handler, ok := instance.handlers[text]; ok {
-			instance.callHandler(handler, uctx)
-			return
-		}
+	instance.callHandler(instance.findHandler(uctx), uctx)
+}
 
-		// Try command match (e.g., "/start" matches "/start@botname")
-		for pattern, handler := range instance.handlers {
-			if len(pattern) > 0 && pattern[0] == '/' {
-				// Command pattern
-				cmdLen := len(pattern)
-				if len(text) >= cmdLen && text[:cmdLen] == pattern {
-					if len(text) == cmdLen || text[cmdLen] == ' ' || text[cmdLen] == '@' {
-						instance.callHandler(handler, uctx)
-						return
-					}
-				}
+// findHandler returns the handler registered for an update, falling back to
+// the default handler (which may be nil). Captured groups are stored on uctx.
+func (instance *BotInstance) findHandler(uctx *UpdateContext) goja.Callable {
+	update := uctx.update
+
+	var m *routeMatch
+	switch {
+	case update.CallbackQuery != nil:
+		m = instance.callbacks.match(update.CallbackQuery.Data)
+	case update.Message != nil:
+		if cmd := parseCommand(update.Message.Text); cmd != nil {
+			// Commands addressed to another bot in a group are not ours
+			if !cmd.addressedTo(instance.username) {
+				return nil
 			}
+			uctx.command = cmd
 		}
+		m = instance.handlers.match(update.Message.Text)
+	case update.InlineQuery != nil:
+		m = instance.inlineQueries.match(update.InlineQuery.Query)
+	case update.ShippingQuery != nil:
+		m = instance.shippingQueries.match(update.ShippingQuery.InvoicePayload)
+	case update.PreCheckoutQuery != nil:
+		m = instance.preCheckoutQueries.match(update.PreCheckoutQuery.InvoicePayload)
+	default:
+		return instance.events[updateType(update)]
+	}
 
-		// Default handler
-		if instance.defaultHandler != nil {
-			instance.callHandler(instance.defaultHandler, uctx)
+	if m == nil {
+		// bot.on catches what no pattern matched
+		if handler := instance.events[updateType(update)]; handler != nil {
+			return handler
 		}
+		return instance.defaultHandler
 	}
+	uctx.match = m.match
+	uctx.params = m.params
+	return m.handler
 }
 
-// callHandler safely calls a JavaScript handler with panic recovery
+// callHandler safely runs the middleware chain and handler with panic
+// recovery. Thrown exceptions and panics go to the error handler.
 func (instance *BotInstance) callHandler(handler goja.Callable, uctx *UpdateContext) {
+	if handler == nil && len(instance.middlewares) == 0 {
+		return
+	}
+
+	var ctxObj map[string]interface{}
 	defer func() {
 		if r := recover(); r != nil {
-			fmt.Printf("[ERROR] Handler panic: %v\n%s\n", r, debug.Stack())
+			instance.handleError(&panicError{value: r, stack: string(debug.Stack())}, uctx, ctxObj)
 		}
 	}()
 
-	ctxObj := instance.createContextObject(uctx)
-	handler(goja.Undefined(), instance.runtime.ToValue(ctxObj))
+	if instance.sessions != nil {
+		instance.loadSession(uctx)
+	}
+
+	ctxObj = instance.createContextObject(uctx)
+	if err := instance.runChain(handler, instance.runtime.ToValue(ctxObj)); err != nil {
+		// The session is not saved so a failed handler leaves no partial state
+		instance.handleError(err, uctx, ctxObj)
+		return
+	}
+
+	if instance.sessions != nil {
+		instance.saveSession(uctx, ctxObj)
+	}
 }
 
 // createContextObject creates the context object passed to handlers
 func (instance *BotInstance) createContextObject(uctx *UpdateContext) map[string]interface{} {
 	ctx := map[string]interface{}{
 		"update":                  uctx.convertUpdate(),
+		"state":                   map[string]interface{}{},
 		"reply":                   uctx.createReply(),
 		"replyPhoto":              uctx.createReplyPhoto(),
 		"replySticker":            uctx.createReplySticker(),
 		"replyWithKeyboard":       uctx.createReplyWithKeyboard(),
 		"replyWithInlineKeyboard": uctx.createReplyWithInlineKeyboard(),
 		"answerCallback":          uctx.createAnswerCallback(),
+		"answerInlineQuery":       uctx.createAnswerInlineQuery(),
+		"answerShippingQuery":     uctx.createAnswerShippingQuery(),
+		"answerPreCheckoutQuery":  uctx.createAnswerPreCheckoutQuery(),
+		"enter":                   uctx.createEnter(),
+		"leave":                   uctx.createLeave(),
+		"wizard":                  uctx.createWizard(),
 		"editMessage":             uctx.createEditMessage(),
 		"deleteMessage":           uctx.createDeleteMessage(),
+		"downloadPhoto":           uctx.createDownloadPhoto(),
+		"approveJoinRequest":      uctx.createApproveJoinRequest(),
+		"declineJoinRequest":      uctx.createDeclineJoinRequest(),
+	}
+	if msg := uctx.getMessage(); msg != nil {
+		ctx["message"] = uctx.messageObject(msg)
+	}
+	if uctx.session != nil {
+		ctx["session"] = uctx.session
+	}
+	if uctx.match != nil {
+		ctx["match"] = uctx.match
+		ctx["params"] = uctx.params
+	}
+	if uctx.command != nil {
+		ctx["command"] = uctx.command.name
+		ctx["args"] = uctx.command.args
+		ctx["payload"] = uctx.command.payload
 	}
 	return ctx
 }
 
 // Handler registration methods
-func (instance *BotInstance) createHandle() func(string, goja.Callable) {
-	return func(pattern string, handler goja.Callable) {
-		instance.handlers[pattern] = handler
+func (instance *BotInstance) createHandle() func(goja.Value, goja.Callable) error {
+	return func(pattern goja.Value, handler goja.Callable) error {
+		rt, err := newRoute(pattern, handler)
+		if err != nil {
+			return err
+		}
+		instance.handlers.add(rt)
+		return nil
 	}
 }
 
-func (instance *BotInstance) createHandleCallback() func(string, goja.Callable) {
-	return func(data string, handler goja.Callable) {
-		instance.callbacks[data] = handler
+func (instance *BotInstance) createHandleCallback() func(goja.Value, goja.Callable) error {
+	return func(data goja.Value, handler goja.Callable) error {
+		rt, err := newRoute(data, handler)
+		if err != nil {
+			return err
+		}
+		instance.callbacks.add(rt)
+		return nil
+	}
+}
+
+// createRoutes lists registered routes in the order they are resolved
+func (instance *BotInstance) createRoutes() func() []map[string]interface{} {
+	return func() []map[string]interface{} {
+		routes := instance.handlers.describe("message")
+		routes = append(routes, instance.callbacks.describe("callback")...)
+		routes = append(routes, instance.inlineQueries.describe("inline_query")...)
+		routes = append(routes, instance.shippingQueries.describe("shipping_query")...)
+		routes = append(routes, instance.preCheckoutQueries.describe("pre_checkout_query")...)
+		if instance.defaultHandler != nil {
+			routes = append(routes, map[string]interface{}{
+				"source": "any",
+				"kind":   "default",
+			})
+		}
+		return routes
 	}
 }
 
@@ -118,12 +217,98 @@ func (instance *BotInstance) createHandleDefault() func(goja.Callable) {
 	}
 }
 
+// getMessage returns the message carried by a message-like update (message,
+// edited message, channel post or edited channel post)
+func (uctx *UpdateContext) getMessage() *models.Message {
+	u := uctx.update
+	switch {
+	case u.Message != nil:
+		return u.Message
+	case u.EditedMessage != nil:
+		return u.EditedMessage
+	case u.ChannelPost != nil:
+		return u.ChannelPost
+	case u.EditedChannelPost != nil:
+		return u.EditedChannelPost
+	case u.BusinessMessage != nil:
+		return u.BusinessMessage
+	case u.EditedBusinessMessage != nil:
+		return u.EditedBusinessMessage
+	}
+	return nil
+}
+
+// getBusinessConnectionID returns the business connection the update's
+// message came through, so replies are sent on behalf of the business
+// account. Empty for ordinary messages.
+func (uctx *UpdateContext) getBusinessConnectionID() string {
+	if msg := uctx.getMessage(); msg != nil {
+		return msg.BusinessConnectionID
+	}
+	if cq := uctx.update.CallbackQuery; cq != nil && cq.Message.Message != nil {
+		return cq.Message.Message.BusinessConnectionID
+	}
+	return ""
+}
+
 func (uctx *UpdateContext) getChatID() int64 {
-	if uctx.update.Message != nil {
-		return uctx.update.Message.Chat.ID
+	if msg := uctx.getMessage(); msg != nil {
+		return msg.Chat.ID
 	}
-	if uctx.update.CallbackQuery != nil && uctx.update.CallbackQuery.Message.Message != nil {
-		return uctx.update.CallbackQuery.Message.Message.Chat.ID
+	u := uctx.update
+	switch {
+	case u.CallbackQuery != nil && u.CallbackQuery.Message.Message != nil:
+		return u.CallbackQuery.Message.Message.Chat.ID
+	case u.MyChatMember != nil:
+		return u.MyChatMember.Chat.ID
+	case u.ChatMember != nil:
+		return u.ChatMember.Chat.ID
+	case u.ChatJoinRequest != nil:
+		return u.ChatJoinRequest.Chat.ID
+	case u.MessageReaction != nil:
+		return u.MessageReaction.Chat.ID
+	case u.MessageReactionCount != nil:
+		return u.MessageReactionCount.Chat.ID
+	case u.ChatBoost != nil:
+		return u.ChatBoost.Chat.ID
+	case u.RemovedChatBoost != nil:
+		return u.RemovedChatBoost.Chat.ID
 	}
 	return 0
 }
+
+// getUserID returns the ID of the user who sent the update, or 0
+func (uctx *UpdateContext) getUserID() int64 {
+	u := uctx.update
+	var from *models.User
+	switch {
+	case uctx.getMessage() != nil:
+		from = uctx.getMessage().From
+	case u.CallbackQuery != nil:
+		return u.CallbackQuery.From.ID
+	case u.InlineQuery != nil:
+		from = u.InlineQuery.From
+	case u.ChosenInlineResult != nil:
+		return u.ChosenInlineResult.From.ID
+	case u.ShippingQuery != nil:
+		from = u.ShippingQuery.From
+	case u.PreCheckoutQuery != nil:
+		from = u.PreCheckoutQuery.From
+	case u.MyChatMember != nil:
+		return u.MyChatMember.From.ID
+	case u.ChatMember != nil:
+		return u.ChatMember.From.ID
+	case u.ChatJoinRequest != nil:
+		return u.ChatJoinRequest.From.ID
+	case u.MessageReaction != nil:
+		from = u.MessageReaction.User
+	case u.PollAnswer != nil:
+		from = u.PollAnswer.User
+	case u.PurchasedPaidMedia != nil:
+		return u.PurchasedPaidMedia.From.ID
+	}
+	if from == nil {
+		return 0
+	}
+	return from.ID
+}
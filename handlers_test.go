@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/go-telegram/bot/models"
+)
+
+func TestBusinessMessageContext(t *testing.T) {
+	business := &models.Message{
+		ID:                   5,
+		Chat:                 models.Chat{ID: 42, Type: "private"},
+		Text:                 "hello",
+		BusinessConnectionID: "conn-1",
+	}
+	tests := []struct {
+		name   string
+		update *models.Update
+		connID string
+	}{
+		{"business_message", &models.Update{BusinessMessage: business}, "conn-1"},
+		{"edited_business_message", &models.Update{EditedBusinessMessage: business}, "conn-1"},
+		{"message", &models.Update{Message: &models.Message{Chat: models.Chat{ID: 42}}}, ""},
+	}
+	for _, tt := range tests {
+		uctx := &UpdateContext{update: tt.update}
+		if uctx.getMessage() == nil {
+			t.Errorf("%s: no message", tt.name)
+		}
+		if id := uctx.getChatID(); id != 42 {
+			t.Errorf("%s: chat %d, want 42", tt.name, id)
+		}
+		if id := uctx.getBusinessConnectionID(); id != tt.connID {
+			t.Errorf("%s: business connection %q, want %q", tt.name, id, tt.connID)
+		}
+	}
+}
@@ -0,0 +1,370 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/dop251/goja"
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/spf13/cast"
+)
+
+// Inline query handlers
+
+func (instance *BotInstance) createHandleInlineQuery() func(goja.Value, goja.Callable) error {
+	return func(pattern goja.Value, handler goja.Callable) error {
+		rt, err := newRoute(pattern, handler)
+		if err != nil {
+			return err
+		}
+		instance.inlineQueries.add(rt)
+		return nil
+	}
+}
+
+func (uctx *UpdateContext) createAnswerInlineQuery() func(interface{}, map[string]interface{}) error {
+	return func(results interface{}, options map[string]interface{}) error {
+		if uctx.update.InlineQuery == nil {
+			return fmt.Errorf("no inline query to answer")
+		}
+		return uctx.instance.answerInlineQuery(uctx.update.InlineQuery.ID, results, options)
+	}
+}
+
+func (instance *BotInstance) createAnswerInlineQuery() func(string, interface{}, map[string]interface{}) error {
+	return func(inlineQueryID string, results interface{}, options map[string]interface{}) error {
+		return instance.answerInlineQuery(inlineQueryID, results, options)
+	}
+}
+
+func (instance *BotInstance) answerInlineQuery(inlineQueryID string, results interface{}, options map[string]interface{}) error {
+	converted, err := buildInlineResults(results)
+	if err != nil {
+		return err
+	}
+
+	params := &bot.AnswerInlineQueryParams{
+		InlineQueryID: inlineQueryID,
+		Results:       converted,
+	}
+
+	if options != nil {
+		params.CacheTime = cast.ToInt(options["cacheTime"])
+		params.IsPersonal = cast.ToBool(options["isPersonal"])
+		params.NextOffset = cast.ToString(options["nextOffset"])
+		if btn, ok := options["button"].(map[string]interface{}); ok {
+			button := &models.InlineQueryResultsButton{
+				Text:           cast.ToString(btn["text"]),
+				StartParameter: cast.ToString(btn["startParameter"]),
+			}
+			if webAppURL := cast.ToString(btn["webAppUrl"]); webAppURL != "" {
+				button.WebApp = &models.WebAppInfo{URL: webAppURL}
+			}
+			params.Button = button
+		}
+	}
+
+	_, err = instance.bot.AnswerInlineQuery(instance.ctx, params)
+	return instance.apiError(err)
+}
+
+// Inline result builders exposed as bot.inline.*
+
+func createInlineBuilders() map[string]interface{} {
+	return map[string]interface{}{
+		"article": func(id, title, text string, options map[string]interface{}) map[string]interface{} {
+			return inlineResult(options, map[string]interface{}{
+				"type": "article", "id": id, "title": title, "text": text,
+			})
+		},
+		"photo": func(id, photoURL string, options map[string]interface{}) map[string]interface{} {
+			return inlineResult(options, map[string]interface{}{
+				"type": "photo", "id": id, "photoUrl": photoURL,
+			})
+		},
+		"gif": func(id, gifURL string, options map[string]interface{}) map[string]interface{} {
+			return inlineResult(options, map[string]interface{}{
+				"type": "gif", "id": id, "gifUrl": gifURL,
+			})
+		},
+		"video": func(id, videoURL, title string, options map[string]interface{}) map[string]interface{} {
+			return inlineResult(options, map[string]interface{}{
+				"type": "video", "id": id, "videoUrl": videoURL, "title": title,
+			})
+		},
+		"document": func(id, documentURL, title string, options map[string]interface{}) map[string]interface{} {
+			return inlineResult(options, map[string]interface{}{
+				"type": "document", "id": id, "documentUrl": documentURL, "title": title,
+			})
+		},
+		"cached": func(fileType, id, fileID string, options map[string]interface{}) map[string]interface{} {
+			return inlineResult(options, map[string]interface{}{
+				"type": "cached", "fileType": fileType, "id": id, "fileId": fileID,
+			})
+		},
+	}
+}
+
+// inlineResult merges builder options into the required result fields
+func inlineResult(options, result map[string]interface{}) map[string]interface{} {
+	for k, v := range options {
+		if _, ok := result[k]; !ok {
+			result[k] = v
+		}
+	}
+	return result
+}
+
+// buildInlineResults converts a JS array of result objects
+func buildInlineResults(value interface{}) ([]models.InlineQueryResult, error) {
+	items, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("results must be an array")
+	}
+
+	results := make([]models.InlineQueryResult, 0, len(items))
+	for i, item := range items {
+		r, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("result %d must be an object", i)
+		}
+		result, err := buildInlineResult(r)
+		if err != nil {
+			return nil, fmt.Errorf("result %d: %w", i, err)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// inlineResultFields holds the fields shared by all inline result types
+type inlineResultFields struct {
+	id           string
+	title        string
+	description  string
+	caption      string
+	thumbnailURL string
+	parseMode    models.ParseMode
+	markup       models.ReplyMarkup
+	content      models.InputMessageContent
+}
+
+func buildInlineResult(r map[string]interface{}) (models.InlineQueryResult, error) {
+	f := inlineResultFields{
+		id:           cast.ToString(r["id"]),
+		title:        cast.ToString(r["title"]),
+		description:  cast.ToString(r["description"]),
+		caption:      cast.ToString(r["caption"]),
+		thumbnailURL: cast.ToString(r["thumbnailUrl"]),
+		parseMode:    models.ParseModeHTML,
+	}
+	if f.id == "" {
+		return nil, fmt.Errorf("id is required")
+	}
+	if pm := cast.ToString(r["parseMode"]); pm != "" {
+		f.parseMode = models.ParseMode(pm)
+	}
+	if kb := r["inlineKeyboard"]; kb != nil {
+		if keyboard := convertToKeyboardRows(kb); keyboard != nil {
+			f.markup = buildInlineKeyboard(keyboard)
+		}
+	}
+	if text := cast.ToString(r["text"]); text != "" {
+		content := &models.InputTextMessageContent{
+			MessageText: text,
+			ParseMode:   f.parseMode,
+		}
+		if disablePreview, ok := r["disableWebPagePreview"].(bool); ok && disablePreview {
+			disabled := true
+			content.LinkPreviewOptions = &models.LinkPreviewOptions{
+				IsDisabled: &disabled,
+			}
+		}
+		f.content = content
+	}
+
+	switch cast.ToString(r["type"]) {
+	case "article":
+		if f.content == nil {
+			return nil, fmt.Errorf("article requires text")
+		}
+		return &models.InlineQueryResultArticle{
+			ID:                  f.id,
+			Title:               f.title,
+			InputMessageContent: f.content,
+			ReplyMarkup:         f.markup,
+			URL:                 cast.ToString(r["url"]),
+			Description:         f.description,
+			ThumbnailURL:        f.thumbnailURL,
+		}, nil
+	case "photo":
+		photoURL := cast.ToString(r["photoUrl"])
+		if f.thumbnailURL == "" {
+			f.thumbnailURL = photoURL
+		}
+		return &models.InlineQueryResultPhoto{
+			ID:                  f.id,
+			PhotoURL:            photoURL,
+			ThumbnailURL:        f.thumbnailURL,
+			PhotoWidth:          cast.ToInt(r["width"]),
+			PhotoHeight:         cast.ToInt(r["height"]),
+			Title:               f.title,
+			Description:         f.description,
+			Caption:             f.caption,
+			ParseMode:           f.parseMode,
+			ReplyMarkup:         f.markup,
+			InputMessageContent: f.content,
+		}, nil
+	case "gif":
+		gifURL := cast.ToString(r["gifUrl"])
+		if f.thumbnailURL == "" {
+			f.thumbnailURL = gifURL
+		}
+		return &models.InlineQueryResultGif{
+			ID:                  f.id,
+			GifURL:              gifURL,
+			GifWidth:            cast.ToInt(r["width"]),
+			GifHeight:           cast.ToInt(r["height"]),
+			GifDuration:         cast.ToInt(r["duration"]),
+			ThumbnailURL:        f.thumbnailURL,
+			Title:               f.title,
+			Caption:             f.caption,
+			ParseMode:           f.parseMode,
+			ReplyMarkup:         f.markup,
+			InputMessageContent: f.content,
+		}, nil
+	case "video":
+		mimeType := cast.ToString(r["mimeType"])
+		if mimeType == "" {
+			mimeType = "video/mp4"
+		}
+		return &models.InlineQueryResultVideo{
+			ID:                  f.id,
+			VideoURL:            cast.ToString(r["videoUrl"]),
+			MimeType:            mimeType,
+			ThumbnailURL:        f.thumbnailURL,
+			Title:               f.title,
+			Caption:             f.caption,
+			ParseMode:           f.parseMode,
+			VideoWidth:          cast.ToInt(r["width"]),
+			VideoHeight:         cast.ToInt(r["height"]),
+			VideoDuration:       cast.ToInt(r["duration"]),
+			Description:         f.description,
+			ReplyMarkup:         f.markup,
+			InputMessageContent: f.content,
+		}, nil
+	case "document":
+		mimeType := cast.ToString(r["mimeType"])
+		if mimeType == "" {
+			mimeType = "application/pdf"
+		}
+		return &models.InlineQueryResultDocument{
+			ID:                  f.id,
+			Title:               f.title,
+			Caption:             f.caption,
+			ParseMode:           f.parseMode,
+			DocumentURL:         cast.ToString(r["documentUrl"]),
+			MimeType:            mimeType,
+			Description:         f.description,
+			ReplyMarkup:         f.markup,
+			InputMessageContent: f.content,
+			ThumbnailURL:        f.thumbnailURL,
+		}, nil
+	case "cached":
+		return buildCachedInlineResult(cast.ToString(r["fileType"]), cast.ToString(r["fileId"]), f)
+	default:
+		return nil, fmt.Errorf("unsupported result type %q", cast.ToString(r["type"]))
+	}
+}
+
+// buildCachedInlineResult builds a result referencing a file already stored
+// on Telegram servers
+func buildCachedInlineResult(fileType, fileID string, f inlineResultFields) (models.InlineQueryResult, error) {
+	if fileID == "" {
+		return nil, fmt.Errorf("cached result requires fileId")
+	}
+
+	switch fileType {
+	case "photo":
+		return &models.InlineQueryResultCachedPhoto{
+			ID:                  f.id,
+			PhotoFileID:         fileID,
+			Title:               f.title,
+			Description:         f.description,
+			Caption:             f.caption,
+			ParseMode:           f.parseMode,
+			ReplyMarkup:         f.markup,
+			InputMessageContent: f.content,
+		}, nil
+	case "gif":
+		return &models.InlineQueryResultCachedGif{
+			ID:                  f.id,
+			GifFileID:           fileID,
+			Title:               f.title,
+			Caption:             f.caption,
+			ParseMode:           f.parseMode,
+			ReplyMarkup:         f.markup,
+			InputMessageContent: f.content,
+		}, nil
+	case "mpeg4_gif":
+		return &models.InlineQueryResultCachedMpeg4Gif{
+			ID:                  f.id,
+			Mpeg4FileID:         fileID,
+			Title:               f.title,
+			Caption:             f.caption,
+			ParseMode:           f.parseMode,
+			ReplyMarkup:         f.markup,
+			InputMessageContent: f.content,
+		}, nil
+	case "sticker":
+		return &models.InlineQueryResultCachedSticker{
+			ID:                  f.id,
+			StickerFileID:       fileID,
+			ReplyMarkup:         f.markup,
+			InputMessageContent: f.content,
+		}, nil
+	case "document":
+		return &models.InlineQueryResultCachedDocument{
+			ID:                  f.id,
+			Title:               f.title,
+			DocumentFileID:      fileID,
+			Description:         f.description,
+			Caption:             f.caption,
+			ParseMode:           f.parseMode,
+			ReplyMarkup:         f.markup,
+			InputMessageContent: f.content,
+		}, nil
+	case "video":
+		return &models.InlineQueryResultCachedVideo{
+			ID:                  f.id,
+			VideoFileID:         fileID,
+			Title:               f.title,
+			Description:         f.description,
+			Caption:             f.caption,
+			ParseMode:           f.parseMode,
+			ReplyMarkup:         f.markup,
+			InputMessageContent: f.content,
+		}, nil
+	case "voice":
+		return &models.InlineQueryResultCachedVoice{
+			ID:                  f.id,
+			VoiceFileID:         fileID,
+			Title:               f.title,
+			Caption:             f.caption,
+			ParseMode:           f.parseMode,
+			ReplyMarkup:         f.markup,
+			InputMessageContent: f.content,
+		}, nil
+	case "audio":
+		return &models.InlineQueryResultCachedAudio{
+			ID:                  f.id,
+			AudioFileID:         fileID,
+			Caption:             f.caption,
+			ParseMode:           f.parseMode,
+			ReplyMarkup:         f.markup,
+			InputMessageContent: f.content,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported cached file type %q", fileType)
+	}
+}
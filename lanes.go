@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+
+	"github.com/go-telegram/bot/models"
+)
+
+const (
+	defaultLanes    = 16
+	laneQueueLength = 64
+)
+
+// updateLanes fans updates out to a fixed set of sequential workers. Updates
+// with the same key (chat, or user for inline queries) always land in the
+// same lane and are processed strictly in order, while different lanes take
+// turns on the event loop.
+type updateLanes struct {
+	queues []chan *models.Update
+}
+
+func newUpdateLanes(ctx context.Context, count int, process func(*models.Update)) *updateLanes {
+	if count <= 0 {
+		count = defaultLanes
+	}
+	lanes := &updateLanes{
+		queues: make([]chan *models.Update, count),
+	}
+	for i := range lanes.queues {
+		queue := make(chan *models.Update, laneQueueLength)
+		lanes.queues[i] = queue
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case update := <-queue:
+					process(update)
+				}
+			}
+		}()
+	}
+	return lanes
+}
+
+// push enqueues an update into its lane, blocking while the lane is full
+func (lanes *updateLanes) push(ctx context.Context, update *models.Update) error {
+	key := uint64(updateLaneKey(update))
+	select {
+	case lanes.queues[key%uint64(len(lanes.queues))] <- update:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// updateLaneKey returns the chat (or user) an update belongs to
+func updateLaneKey(u *models.Update) int64 {
+	switch {
+	case u.Message != nil:
+		return u.Message.Chat.ID
+	case u.EditedMessage != nil:
+		return u.EditedMessage.Chat.ID
+	case u.ChannelPost != nil:
+		return u.ChannelPost.Chat.ID
+	case u.EditedChannelPost != nil:
+		return u.EditedChannelPost.Chat.ID
+	case u.BusinessMessage != nil:
+		return u.BusinessMessage.Chat.ID
+	case u.EditedBusinessMessage != nil:
+		return u.EditedBusinessMessage.Chat.ID
+	case u.CallbackQuery != nil:
+		if u.CallbackQuery.Message.Message != nil {
+			return u.CallbackQuery.Message.Message.Chat.ID
+		}
+		return u.CallbackQuery.From.ID
+	case u.InlineQuery != nil:
+		if u.InlineQuery.From != nil {
+			return u.InlineQuery.From.ID
+		}
+	case u.ChosenInlineResult != nil:
+		return u.ChosenInlineResult.From.ID
+	case u.ShippingQuery != nil:
+		if u.ShippingQuery.From != nil {
+			return u.ShippingQuery.From.ID
+		}
+	case u.PreCheckoutQuery != nil:
+		if u.PreCheckoutQuery.From != nil {
+			return u.PreCheckoutQuery.From.ID
+		}
+	case u.MyChatMember != nil:
+		return u.MyChatMember.Chat.ID
+	case u.ChatMember != nil:
+		return u.ChatMember.Chat.ID
+	case u.ChatJoinRequest != nil:
+		return u.ChatJoinRequest.Chat.ID
+	case u.MessageReaction != nil:
+		return u.MessageReaction.Chat.ID
+	case u.MessageReactionCount != nil:
+		return u.MessageReactionCount.Chat.ID
+	case u.DeletedBusinessMessages != nil:
+		return u.DeletedBusinessMessages.Chat.ID
+	case u.BusinessConnection != nil:
+		return u.BusinessConnection.UserChatID
+	case u.PurchasedPaidMedia != nil:
+		return u.PurchasedPaidMedia.From.ID
+	case u.PollAnswer != nil:
+		if u.PollAnswer.User != nil {
+			return u.PollAnswer.User.ID
+		}
+		if u.PollAnswer.VoterChat != nil {
+			return u.PollAnswer.VoterChat.ID
+		}
+	case u.ChatBoost != nil:
+		return u.ChatBoost.Chat.ID
+	case u.RemovedChatBoost != nil:
+		return u.RemovedChatBoost.Chat.ID
+	}
+	return 0
+}
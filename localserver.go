@@ -0,0 +1,51 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/go-telegram/bot/models"
+
+	"github.com/levskiy0/m3m/pkg/plugin"
+)
+
+// localFile returns a file:// reference for a path when the bot talks to a
+// local Bot API server, which reads files straight from disk instead of
+// receiving an upload. It returns nil in cloud mode or when path is not a
+// local file.
+func (instance *BotInstance) localFile(path string) *models.InputFileString {
+	if !instance.localMode {
+		return nil
+	}
+	if strings.HasPrefix(path, "file://") {
+		return &models.InputFileString{Data: path}
+	}
+	if !plugin.IsFilePath(path) {
+		return nil
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil
+	}
+	return &models.InputFileString{Data: "file://" + abs}
+}
+
+// createLogOut logs the bot out of the cloud Bot API server so it can be
+// moved to a local server. Updates stop until the bot is started against the
+// new server, and it cannot log back in to the cloud for 10 minutes.
+func (instance *BotInstance) createLogOut() func() error {
+	return func() error {
+		_, err := instance.bot.Logout(instance.ctx)
+		return instance.apiError(err)
+	}
+}
+
+// createClose closes the bot instance on the current server before it is
+// moved to another local server. Telegram rejects the call during the first
+// 10 minutes after the bot is launched.
+func (instance *BotInstance) createClose() func() error {
+	return func() error {
+		_, err := instance.bot.Close(instance.ctx)
+		return instance.apiError(err)
+	}
+}
@@ -23,12 +23,13 @@ func (uctx *UpdateContext) createReply() func(string) (map[string]interface{}, e
 		}
 
 		msg, err := uctx.instance.bot.SendMessage(uctx.instance.ctx, &bot.SendMessageParams{
-			ChatID:    chatID,
-			Text:      text,
-			ParseMode: models.ParseModeHTML,
+			BusinessConnectionID: uctx.getBusinessConnectionID(),
+			ChatID:               chatID,
+			Text:                 text,
+			ParseMode:            models.ParseModeHTML,
 		})
 		if err != nil {
-			return nil, err
+			return nil, uctx.instance.apiError(err)
 		}
 		return uctx.convertMessage(msg), nil
 	}
@@ -42,16 +43,19 @@ func (uctx *UpdateContext) createReplyPhoto() func(string, string) (map[string]i
 		}
 
 		params := &bot.SendPhotoParams{
-			ChatID:    chatID,
-			Caption:   caption,
-			ParseMode: models.ParseModeHTML,
+			BusinessConnectionID: uctx.getBusinessConnectionID(),
+			ChatID:               chatID,
+			Caption:              caption,
+			ParseMode:            models.ParseModeHTML,
 		}
 
 		// Resolve path relative to storage
 		photo = plugin.MustResolvePath(uctx.instance.storagePath, photo)
 
 		// Check if it's a file path, URL or file_id
-		if plugin.IsFilePath(photo) {
+		if local := uctx.instance.localFile(photo); local != nil {
+			params.Photo = local
+		} else if plugin.IsFilePath(photo) {
 			data, err := os.ReadFile(photo)
 			if err != nil {
 				return nil, fmt.Errorf("failed to read file: %w", err)
@@ -75,7 +79,7 @@ func (uctx *UpdateContext) createReplyPhoto() func(string, string) (map[string]i
 
 		msg, err := uctx.instance.bot.SendPhoto(uctx.instance.ctx, params)
 		if err != nil {
-			return nil, err
+			return nil, uctx.instance.apiError(err)
 		}
 		return uctx.convertMessage(msg), nil
 	}
@@ -95,13 +99,14 @@ func (uctx *UpdateContext) createReplyWithKeyboard() func(string, interface{}, m
 		kb := buildReplyKeyboard(keyboard, options)
 
 		msg, err := uctx.instance.bot.SendMessage(uctx.instance.ctx, &bot.SendMessageParams{
-			ChatID:      chatID,
-			Text:        text,
-			ParseMode:   models.ParseModeHTML,
-			ReplyMarkup: kb,
+			BusinessConnectionID: uctx.getBusinessConnectionID(),
+			ChatID:               chatID,
+			Text:                 text,
+			ParseMode:            models.ParseModeHTML,
+			ReplyMarkup:          kb,
 		})
 		if err != nil {
-			return nil, err
+			return nil, uctx.instance.apiError(err)
 		}
 		return uctx.convertMessage(msg), nil
 	}
@@ -121,13 +126,14 @@ func (uctx *UpdateContext) createReplyWithInlineKeyboard() func(string, interfac
 		kb := buildInlineKeyboard(keyboard)
 
 		msg, err := uctx.instance.bot.SendMessage(uctx.instance.ctx, &bot.SendMessageParams{
-			ChatID:      chatID,
-			Text:        text,
-			ParseMode:   models.ParseModeHTML,
-			ReplyMarkup: kb,
+			BusinessConnectionID: uctx.getBusinessConnectionID(),
+			ChatID:               chatID,
+			Text:                 text,
+			ParseMode:            models.ParseModeHTML,
+			ReplyMarkup:          kb,
 		})
 		if err != nil {
-			return nil, err
+			return nil, uctx.instance.apiError(err)
 		}
 		return uctx.convertMessage(msg), nil
 	}
@@ -143,7 +149,7 @@ func (uctx *UpdateContext) createAnswerCallback() func(string, bool) error {
 			Text:            text,
 			ShowAlert:       showAlert,
 		})
-		return err
+		return uctx.instance.apiError(err)
 	}
 }
 
@@ -163,10 +169,11 @@ func (uctx *UpdateContext) createEditMessage() func(string, map[string]interface
 		}
 
 		params := &bot.EditMessageTextParams{
-			ChatID:    chatID,
-			MessageID: messageID,
-			Text:      text,
-			ParseMode: models.ParseModeHTML,
+			BusinessConnectionID: uctx.getBusinessConnectionID(),
+			ChatID:               chatID,
+			MessageID:            messageID,
+			Text:                 text,
+			ParseMode:            models.ParseModeHTML,
 		}
 
 		// Handle options
@@ -186,7 +193,7 @@ func (uctx *UpdateContext) createEditMessage() func(string, map[string]interface
 
 		msg, err := uctx.instance.bot.EditMessageText(uctx.instance.ctx, params)
 		if err != nil {
-			return nil, err
+			return nil, uctx.instance.apiError(err)
 		}
 		return uctx.convertMessage(msg), nil
 	}
@@ -197,9 +204,9 @@ func (uctx *UpdateContext) createDeleteMessage() func() error {
 		var chatID int64
 		var messageID int
 
-		if uctx.update.Message != nil {
-			chatID = uctx.update.Message.Chat.ID
-			messageID = uctx.update.Message.ID
+		if msg := uctx.getMessage(); msg != nil {
+			chatID = msg.Chat.ID
+			messageID = msg.ID
 		} else if uctx.update.CallbackQuery != nil && uctx.update.CallbackQuery.Message.Message != nil {
 			msg := uctx.update.CallbackQuery.Message.Message
 			chatID = msg.Chat.ID
@@ -214,7 +221,7 @@ func (uctx *UpdateContext) createDeleteMessage() func() error {
 			ChatID:    chatID,
 			MessageID: messageID,
 		})
-		return err
+		return uctx.instance.apiError(err)
 	}
 }
 
@@ -226,14 +233,17 @@ func (uctx *UpdateContext) createReplySticker() func(string) (map[string]interfa
 		}
 
 		params := &bot.SendStickerParams{
-			ChatID: chatID,
+			BusinessConnectionID: uctx.getBusinessConnectionID(),
+			ChatID:               chatID,
 		}
 
 		// Resolve path relative to storage
 		sticker = plugin.MustResolvePath(uctx.instance.storagePath, sticker)
 
 		// Check if it's a file path, base64, URL or file_id
-		if plugin.IsFilePath(sticker) {
+		if local := uctx.instance.localFile(sticker); local != nil {
+			params.Sticker = local
+		} else if plugin.IsFilePath(sticker) {
 			data, err := os.ReadFile(sticker)
 			if err != nil {
 				return nil, fmt.Errorf("failed to read file: %w", err)
@@ -258,7 +268,7 @@ func (uctx *UpdateContext) createReplySticker() func(string) (map[string]interfa
 
 		msg, err := uctx.instance.bot.SendSticker(uctx.instance.ctx, params)
 		if err != nil {
-			return nil, err
+			return nil, uctx.instance.apiError(err)
 		}
 		return uctx.convertMessage(msg), nil
 	}
@@ -297,6 +307,11 @@ func (instance *BotInstance) createSendMessage() func(int64, string, map[string]
 			if parseMode, ok := options["parseMode"].(string); ok {
 				params.ParseMode = models.ParseMode(parseMode)
 			}
+			// Entities replace parse mode markup, the text is sent as is
+			if entities := parseEntities(options["entities"]); entities != nil {
+				params.Entities = entities
+				params.ParseMode = ""
+			}
 			if disablePreview, ok := options["disableWebPagePreview"].(bool); ok && disablePreview {
 				disabled := true
 				params.LinkPreviewOptions = &models.LinkPreviewOptions{
@@ -307,7 +322,7 @@ func (instance *BotInstance) createSendMessage() func(int64, string, map[string]
 
 		msg, err := instance.bot.SendMessage(instance.ctx, params)
 		if err != nil {
-			return nil, err
+			return nil, instance.apiError(err)
 		}
 		return (&UpdateContext{instance: instance}).convertMessage(msg), nil
 	}
@@ -333,7 +348,9 @@ func (p *TelegramPlugin) createSendPhoto(instance *BotInstance) func(int64, stri
 
 		// Determine photo source
 		photo = plugin.MustResolvePath(p.storagePath, photo)
-		if plugin.IsFilePath(photo) {
+		if local := instance.localFile(photo); local != nil {
+			params.Photo = local
+		} else if plugin.IsFilePath(photo) {
 			data, err := os.ReadFile(photo)
 			if err != nil {
 				return nil, fmt.Errorf("failed to read file: %w", err)
@@ -357,7 +374,7 @@ func (p *TelegramPlugin) createSendPhoto(instance *BotInstance) func(int64, stri
 
 		msg, err := instance.bot.SendPhoto(instance.ctx, params)
 		if err != nil {
-			return nil, err
+			return nil, instance.apiError(err)
 		}
 		return (&UpdateContext{instance: instance}).convertMessage(msg), nil
 	}
@@ -386,7 +403,9 @@ func (p *TelegramPlugin) createSendDocument(instance *BotInstance) func(int64, s
 		}
 
 		document = plugin.MustResolvePath(p.storagePath, document)
-		if plugin.IsFilePath(document) {
+		if local := instance.localFile(document); local != nil {
+			params.Document = local
+		} else if plugin.IsFilePath(document) {
 			data, err := os.ReadFile(document)
 			if err != nil {
 				return nil, fmt.Errorf("failed to read file: %w", err)
@@ -410,7 +429,7 @@ func (p *TelegramPlugin) createSendDocument(instance *BotInstance) func(int64, s
 
 		msg, err := instance.bot.SendDocument(instance.ctx, params)
 		if err != nil {
-			return nil, err
+			return nil, instance.apiError(err)
 		}
 		return (&UpdateContext{instance: instance}).convertMessage(msg), nil
 	}
@@ -434,7 +453,9 @@ func (p *TelegramPlugin) createSendSticker(instance *BotInstance) func(int64, st
 		sticker = plugin.MustResolvePath(p.storagePath, sticker)
 
 		// Check if it's a file path, base64, URL or file_id
-		if plugin.IsFilePath(sticker) {
+		if local := instance.localFile(sticker); local != nil {
+			params.Sticker = local
+		} else if plugin.IsFilePath(sticker) {
 			data, err := os.ReadFile(sticker)
 			if err != nil {
 				return nil, fmt.Errorf("failed to read file: %w", err)
@@ -459,7 +480,7 @@ func (p *TelegramPlugin) createSendSticker(instance *BotInstance) func(int64, st
 
 		msg, err := instance.bot.SendSticker(instance.ctx, params)
 		if err != nil {
-			return nil, err
+			return nil, instance.apiError(err)
 		}
 		return (&UpdateContext{instance: instance}).convertMessage(msg), nil
 	}
@@ -484,7 +505,9 @@ func (p *TelegramPlugin) createSendVideo(instance *BotInstance) func(int64, stri
 		}
 
 		video = plugin.MustResolvePath(p.storagePath, video)
-		if plugin.IsFilePath(video) {
+		if local := instance.localFile(video); local != nil {
+			params.Video = local
+		} else if plugin.IsFilePath(video) {
 			data, err := os.ReadFile(video)
 			if err != nil {
 				return nil, fmt.Errorf("failed to read file: %w", err)
@@ -499,7 +522,7 @@ func (p *TelegramPlugin) createSendVideo(instance *BotInstance) func(int64, stri
 
 		msg, err := instance.bot.SendVideo(instance.ctx, params)
 		if err != nil {
-			return nil, err
+			return nil, instance.apiError(err)
 		}
 		return (&UpdateContext{instance: instance}).convertMessage(msg), nil
 	}
@@ -524,7 +547,9 @@ func (p *TelegramPlugin) createSendAudio(instance *BotInstance) func(int64, stri
 		}
 
 		audio = plugin.MustResolvePath(p.storagePath, audio)
-		if plugin.IsFilePath(audio) {
+		if local := instance.localFile(audio); local != nil {
+			params.Audio = local
+		} else if plugin.IsFilePath(audio) {
 			data, err := os.ReadFile(audio)
 			if err != nil {
 				return nil, fmt.Errorf("failed to read file: %w", err)
@@ -539,7 +564,7 @@ func (p *TelegramPlugin) createSendAudio(instance *BotInstance) func(int64, stri
 
 		msg, err := instance.bot.SendAudio(instance.ctx, params)
 		if err != nil {
-			return nil, err
+			return nil, instance.apiError(err)
 		}
 		return (&UpdateContext{instance: instance}).convertMessage(msg), nil
 	}
@@ -564,7 +589,9 @@ func (p *TelegramPlugin) createSendVoice(instance *BotInstance) func(int64, stri
 		}
 
 		voice = plugin.MustResolvePath(p.storagePath, voice)
-		if plugin.IsFilePath(voice) {
+		if local := instance.localFile(voice); local != nil {
+			params.Voice = local
+		} else if plugin.IsFilePath(voice) {
 			data, err := os.ReadFile(voice)
 			if err != nil {
 				return nil, fmt.Errorf("failed to read file: %w", err)
@@ -579,7 +606,7 @@ func (p *TelegramPlugin) createSendVoice(instance *BotInstance) func(int64, stri
 
 		msg, err := instance.bot.SendVoice(instance.ctx, params)
 		if err != nil {
-			return nil, err
+			return nil, instance.apiError(err)
 		}
 		return (&UpdateContext{instance: instance}).convertMessage(msg), nil
 	}
@@ -604,7 +631,7 @@ func (instance *BotInstance) createEditMessage() func(int64, int, string, map[st
 
 		msg, err := instance.bot.EditMessageText(instance.ctx, params)
 		if err != nil {
-			return nil, err
+			return nil, instance.apiError(err)
 		}
 		return (&UpdateContext{instance: instance}).convertMessage(msg), nil
 	}
@@ -619,8 +646,12 @@ func (p *TelegramPlugin) createEditMessageMedia(instance *BotInstance) func(int6
 			}
 		}
 
-		// Note: EditMessageMedia only supports URL or file_id, not file uploads
+		// Note: EditMessageMedia only supports URL or file_id, not file uploads,
+		// except for file:// paths on a local Bot API server
 		photo = plugin.MustResolvePath(p.storagePath, photo)
+		if local := instance.localFile(photo); local != nil {
+			photo = local.Data
+		}
 		media := &models.InputMediaPhoto{
 			Media:     photo,
 			Caption:   caption,
@@ -643,7 +674,7 @@ func (p *TelegramPlugin) createEditMessageMedia(instance *BotInstance) func(int6
 
 		msg, err := instance.bot.EditMessageMedia(instance.ctx, params)
 		if err != nil {
-			return nil, err
+			return nil, instance.apiError(err)
 		}
 		return (&UpdateContext{instance: instance}).convertMessage(msg), nil
 	}
@@ -655,7 +686,7 @@ func (instance *BotInstance) createDeleteMessage() func(int64, int) error {
 			ChatID:    chatID,
 			MessageID: messageID,
 		})
-		return err
+		return instance.apiError(err)
 	}
 }
 
@@ -666,7 +697,7 @@ func (instance *BotInstance) createAnswerCallback() func(string, string, bool) e
 			Text:            text,
 			ShowAlert:       showAlert,
 		})
-		return err
+		return instance.apiError(err)
 	}
 }
 
@@ -674,7 +705,7 @@ func (instance *BotInstance) createGetMe() func() (map[string]interface{}, error
 	return func() (map[string]interface{}, error) {
 		user, err := instance.bot.GetMe(instance.ctx)
 		if err != nil {
-			return nil, err
+			return nil, instance.apiError(err)
 		}
 		return map[string]interface{}{
 			"id":           user.ID,
@@ -693,14 +724,19 @@ func (instance *BotInstance) createGetFile() func(string) (map[string]interface{
 			FileID: fileID,
 		})
 		if err != nil {
-			return nil, err
+			return nil, instance.apiError(err)
 		}
-		return map[string]interface{}{
+		result := map[string]interface{}{
 			"fileId":       file.FileID,
 			"fileUniqueId": file.FileUniqueID,
 			"fileSize":     file.FileSize,
 			"filePath":     file.FilePath,
-		}, nil
+		}
+		// A local Bot API server returns the absolute path of the file on disk
+		if instance.localMode && filepath.IsAbs(file.FilePath) {
+			result["localPath"] = file.FilePath
+		}
+		return result, nil
 	}
 }
 
@@ -711,41 +747,9 @@ func (instance *BotInstance) createGetChatMember() func(int64, int64) (map[strin
 			UserID: userID,
 		})
 		if err != nil {
-			return nil, err
-		}
-
-		result := map[string]interface{}{
-			"status": string(member.Type),
-		}
-
-		uctx := &UpdateContext{instance: instance}
-		switch member.Type {
-		case models.ChatMemberTypeOwner:
-			if member.Owner != nil {
-				result["user"] = uctx.convertUser(member.Owner.User)
-			}
-		case models.ChatMemberTypeAdministrator:
-			if member.Administrator != nil {
-				result["user"] = uctx.convertUser(&member.Administrator.User)
-			}
-		case models.ChatMemberTypeMember:
-			if member.Member != nil {
-				result["user"] = uctx.convertUser(member.Member.User)
-			}
-		case models.ChatMemberTypeRestricted:
-			if member.Restricted != nil {
-				result["user"] = uctx.convertUser(member.Restricted.User)
-			}
-		case models.ChatMemberTypeLeft:
-			if member.Left != nil {
-				result["user"] = uctx.convertUser(member.Left.User)
-			}
-		case models.ChatMemberTypeBanned:
-			if member.Banned != nil {
-				result["user"] = uctx.convertUser(member.Banned.User)
-			}
+			return nil, instance.apiError(err)
 		}
 
-		return result, nil
+		return (&UpdateContext{instance: instance}).convertChatMember(member), nil
 	}
 }
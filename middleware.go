@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/dop251/goja"
+)
+
+// createUse registers a middleware. Middlewares run in registration order
+// around the matched handler and receive (ctx, next); not calling next stops
+// the chain.
+func (instance *BotInstance) createUse() func(goja.Callable) {
+	return func(middleware goja.Callable) {
+		instance.middlewares = append(instance.middlewares, middleware)
+	}
+}
+
+// runChain executes the middleware chain followed by handler, which may be
+// nil when no handler matched. Exceptions thrown downstream are returned from
+// next() so a middleware can catch them.
+func (instance *BotInstance) runChain(handler goja.Callable, ctxVal goja.Value) error {
+	middlewares := instance.middlewares
+
+	var step func(i int) error
+	step = func(i int) error {
+		if i == len(middlewares) {
+			if handler == nil {
+				return nil
+			}
+			_, err := handler(goja.Undefined(), ctxVal)
+			return err
+		}
+
+		called := false
+		next := func() error {
+			if called {
+				return fmt.Errorf("next() called multiple times")
+			}
+			called = true
+			return step(i + 1)
+		}
+		_, err := middlewares[i](goja.Undefined(), ctxVal, instance.runtime.ToValue(next))
+		return err
+	}
+
+	return step(0)
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+const offsetFile = "offset.json"
+
+// offsetState is the persisted polling position of a bot
+type offsetState struct {
+	// Offset is the first update ID that has not been fully processed; every
+	// update below it has been handled
+	Offset int64 `json:"offset"`
+	// Recent holds the IDs of the last processed updates, oldest first
+	Recent []int64 `json:"recent,omitempty"`
+}
+
+// updateTracker commits update IDs once their handler has returned. Updates
+// are handled concurrently in lanes, so the committed offset only advances
+// past an update once every update received before it is done as well.
+type updateTracker struct {
+	mu     sync.Mutex
+	path   string
+	window int
+	state  offsetState
+	order  []int64
+	done   map[int64]bool
+	recent map[int64]struct{}
+	idle   chan struct{}
+}
+
+// newUpdateTracker restores the tracker from path. An empty path keeps the
+// state in memory only. window is the number of recently processed update
+// IDs remembered for deduplication (0 disables it).
+func newUpdateTracker(path string, window int) *updateTracker {
+	t := &updateTracker{
+		path:   path,
+		window: window,
+		done:   make(map[int64]bool),
+		recent: make(map[int64]struct{}),
+	}
+	if path != "" {
+		if err := readJSONFile(path, &t.state); err != nil {
+			fmt.Printf("[ERROR] Failed to restore update offset: %v\n", err)
+		}
+	}
+	if window <= 0 {
+		t.state.Recent = nil
+	} else if len(t.state.Recent) > window {
+		t.state.Recent = t.state.Recent[len(t.state.Recent)-window:]
+	}
+	for _, id := range t.state.Recent {
+		t.recent[id] = struct{}{}
+	}
+	return t
+}
+
+// offset returns the first update ID that still needs processing
+func (t *updateTracker) offset() int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.state.Offset
+}
+
+// begin registers a received update. It returns false for updates that were
+// already processed, which must be skipped.
+func (t *updateTracker) begin(id int64) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if id < t.state.Offset {
+		return false
+	}
+	if _, ok := t.recent[id]; ok {
+		return false
+	}
+	if _, ok := t.done[id]; ok {
+		return false // already in flight
+	}
+	t.order = append(t.order, id)
+	t.done[id] = false
+	return true
+}
+
+// finish marks an update as processed and persists the new position
+func (t *updateTracker) finish(id int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.done[id]; !ok {
+		return
+	}
+	t.done[id] = true
+	t.remember(id)
+
+	for len(t.order) > 0 && t.done[t.order[0]] {
+		head := t.order[0]
+		delete(t.done, head)
+		t.order = t.order[1:]
+		if head >= t.state.Offset {
+			t.state.Offset = head + 1
+		}
+	}
+	if len(t.order) == 0 && t.idle != nil {
+		close(t.idle)
+		t.idle = nil
+	}
+	t.save()
+}
+
+// remember adds id to the dedup window. Must be called with t.mu held.
+func (t *updateTracker) remember(id int64) {
+	if t.window <= 0 {
+		return
+	}
+	t.recent[id] = struct{}{}
+	t.state.Recent = append(t.state.Recent, id)
+	if len(t.state.Recent) > t.window {
+		delete(t.recent, t.state.Recent[0])
+		t.state.Recent = t.state.Recent[1:]
+	}
+}
+
+// save persists the state. Must be called with t.mu held.
+func (t *updateTracker) save() {
+	if t.path == "" {
+		return
+	}
+	if err := writeJSONFile(t.path, t.state); err != nil {
+		fmt.Printf("[ERROR] Failed to persist update offset: %v\n", err)
+	}
+}
+
+// wait blocks until every registered update has been processed
+func (t *updateTracker) wait(ctx context.Context) error {
+	t.mu.Lock()
+	if len(t.order) == 0 {
+		t.mu.Unlock()
+		return nil
+	}
+	if t.idle == nil {
+		t.idle = make(chan struct{})
+	}
+	idle := t.idle
+	t.mu.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
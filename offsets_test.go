@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestUpdateTrackerCommitsInOrder(t *testing.T) {
+	tracker := newUpdateTracker("", 0)
+	for _, id := range []int64{10, 11, 12} {
+		if !tracker.begin(id) {
+			t.Fatalf("update %d skipped", id)
+		}
+	}
+
+	// A later update finishing first must not move the offset past 10
+	tracker.finish(12)
+	if got := tracker.offset(); got != 0 {
+		t.Fatalf("offset %d after finishing 12 only, want 0", got)
+	}
+	tracker.finish(10)
+	if got := tracker.offset(); got != 11 {
+		t.Fatalf("offset %d after finishing 10, want 11", got)
+	}
+	tracker.finish(11)
+	if got := tracker.offset(); got != 13 {
+		t.Fatalf("offset %d after finishing all, want 13", got)
+	}
+}
+
+func TestUpdateTrackerSkipsProcessedUpdates(t *testing.T) {
+	tracker := newUpdateTracker("", 0)
+	tracker.begin(5)
+	if tracker.begin(5) {
+		t.Fatal("update in flight was accepted twice")
+	}
+	tracker.finish(5)
+	if tracker.begin(5) {
+		t.Fatal("committed update was accepted again")
+	}
+}
+
+func TestUpdateTrackerRestoresOffsetAndDedupWindow(t *testing.T) {
+	path := filepath.Join(t.TempDir(), offsetFile)
+
+	tracker := newUpdateTracker(path, 2)
+	for _, id := range []int64{1, 2, 3, 7} {
+		tracker.begin(id)
+	}
+	for _, id := range []int64{1, 2, 7} {
+		tracker.finish(id)
+	}
+
+	restored := newUpdateTracker(path, 2)
+	if got := restored.offset(); got != 3 {
+		t.Fatalf("restored offset %d, want 3", got)
+	}
+	// 3 was never finished and must be redelivered; 7 was handled out of
+	// order and is remembered by the dedup window
+	if !restored.begin(3) {
+		t.Fatal("unfinished update 3 skipped after restore")
+	}
+	if restored.begin(7) {
+		t.Fatal("processed update 7 accepted again after restore")
+	}
+}
+
+func TestUpdateTrackerWait(t *testing.T) {
+	tracker := newUpdateTracker("", 0)
+	if err := tracker.wait(context.Background()); err != nil {
+		t.Fatalf("wait with nothing in flight: %v", err)
+	}
+
+	tracker.begin(1)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := tracker.wait(ctx); err == nil {
+		t.Fatal("wait returned while update 1 was in flight")
+	}
+
+	go tracker.finish(1)
+	if err := tracker.wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/dop251/goja"
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/spf13/cast"
+)
+
+// currencyStars is the currency code of Telegram Stars. Invoices in Stars
+// must not carry a provider token.
+const currencyStars = "XTR"
+
+// Payment handlers
+
+func (instance *BotInstance) createHandleShippingQuery() func(goja.Value, goja.Callable) error {
+	return func(payload goja.Value, handler goja.Callable) error {
+		rt, err := newRoute(payload, handler)
+		if err != nil {
+			return err
+		}
+		instance.shippingQueries.add(rt)
+		return nil
+	}
+}
+
+func (instance *BotInstance) createHandlePreCheckoutQuery() func(goja.Value, goja.Callable) error {
+	return func(payload goja.Value, handler goja.Callable) error {
+		rt, err := newRoute(payload, handler)
+		if err != nil {
+			return err
+		}
+		instance.preCheckoutQueries.add(rt)
+		return nil
+	}
+}
+
+// Invoices
+
+func (instance *BotInstance) createSendInvoice() func(int64, map[string]interface{}) (map[string]interface{}, error) {
+	return func(chatID int64, invoice map[string]interface{}) (map[string]interface{}, error) {
+		if invoice == nil {
+			return nil, fmt.Errorf("invoice is required")
+		}
+		prices, err := buildLabeledPrices(invoice["prices"])
+		if err != nil {
+			return nil, err
+		}
+
+		params := &bot.SendInvoiceParams{
+			ChatID:                    chatID,
+			Title:                     cast.ToString(invoice["title"]),
+			Description:               cast.ToString(invoice["description"]),
+			Payload:                   cast.ToString(invoice["payload"]),
+			ProviderToken:             cast.ToString(invoice["providerToken"]),
+			Currency:                  cast.ToString(invoice["currency"]),
+			Prices:                    prices,
+			MaxTipAmount:              cast.ToInt(invoice["maxTipAmount"]),
+			SuggestedTipAmounts:       cast.ToIntSlice(invoice["suggestedTipAmounts"]),
+			StartParameter:            cast.ToString(invoice["startParameter"]),
+			ProviderData:              cast.ToString(invoice["providerData"]),
+			PhotoURL:                  cast.ToString(invoice["photoUrl"]),
+			PhotoWidth:                cast.ToInt(invoice["photoWidth"]),
+			PhotoHeight:               cast.ToInt(invoice["photoHeight"]),
+			NeedName:                  cast.ToBool(invoice["needName"]),
+			NeedPhoneNumber:           cast.ToBool(invoice["needPhoneNumber"]),
+			NeedEmail:                 cast.ToBool(invoice["needEmail"]),
+			NeedShippingAddress:       cast.ToBool(invoice["needShippingAddress"]),
+			SendPhoneNumberToProvider: cast.ToBool(invoice["sendPhoneNumberToProvider"]),
+			SendEmailToProvider:       cast.ToBool(invoice["sendEmailToProvider"]),
+			IsFlexible:                cast.ToBool(invoice["isFlexible"]),
+		}
+		if params.Currency == currencyStars {
+			params.ProviderToken = ""
+		}
+		if kb := invoice["inlineKeyboard"]; kb != nil {
+			if keyboard := convertToKeyboardRows(kb); keyboard != nil {
+				params.ReplyMarkup = buildInlineKeyboard(keyboard)
+			}
+		}
+
+		msg, err := instance.bot.SendInvoice(instance.ctx, params)
+		if err != nil {
+			return nil, instance.apiError(err)
+		}
+		return (&UpdateContext{instance: instance}).convertMessage(msg), nil
+	}
+}
+
+func (instance *BotInstance) createCreateInvoiceLink() func(map[string]interface{}) (string, error) {
+	return func(invoice map[string]interface{}) (string, error) {
+		if invoice == nil {
+			return "", fmt.Errorf("invoice is required")
+		}
+		prices, err := buildLabeledPrices(invoice["prices"])
+		if err != nil {
+			return "", err
+		}
+
+		params := &bot.CreateInvoiceLinkParams{
+			Title:                     cast.ToString(invoice["title"]),
+			Description:               cast.ToString(invoice["description"]),
+			Payload:                   cast.ToString(invoice["payload"]),
+			ProviderToken:             cast.ToString(invoice["providerToken"]),
+			Currency:                  cast.ToString(invoice["currency"]),
+			Prices:                    prices,
+			SubscriptionPeriod:        cast.ToInt(invoice["subscriptionPeriod"]),
+			MaxTipAmount:              cast.ToInt(invoice["maxTipAmount"]),
+			SuggestedTipAmounts:       cast.ToIntSlice(invoice["suggestedTipAmounts"]),
+			ProviderData:              cast.ToString(invoice["providerData"]),
+			PhotoURL:                  cast.ToString(invoice["photoUrl"]),
+			PhotoWidth:                cast.ToInt(invoice["photoWidth"]),
+			PhotoHeight:               cast.ToInt(invoice["photoHeight"]),
+			NeedName:                  cast.ToBool(invoice["needName"]),
+			NeedPhoneNumber:           cast.ToBool(invoice["needPhoneNumber"]),
+			NeedEmail:                 cast.ToBool(invoice["needEmail"]),
+			NeedShippingAddress:       cast.ToBool(invoice["needShippingAddress"]),
+			SendPhoneNumberToProvider: cast.ToBool(invoice["sendPhoneNumberToProvider"]),
+			SendEmailToProvider:       cast.ToBool(invoice["sendEmailToProvider"]),
+			IsFlexible:                cast.ToBool(invoice["isFlexible"]),
+		}
+		if params.Currency == currencyStars {
+			params.ProviderToken = ""
+		}
+
+		link, err := instance.bot.CreateInvoiceLink(instance.ctx, params)
+		if err != nil {
+			return "", instance.apiError(err)
+		}
+		return link, nil
+	}
+}
+
+// buildLabeledPrices converts [{label, amount}] from JS. Amounts are in the
+// smallest units of the currency (cents, or whole Stars for XTR).
+func buildLabeledPrices(value interface{}) ([]models.LabeledPrice, error) {
+	items, ok := value.([]interface{})
+	if !ok || len(items) == 0 {
+		return nil, fmt.Errorf("prices must be a non-empty array")
+	}
+
+	prices := make([]models.LabeledPrice, len(items))
+	for i, item := range items {
+		price, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("price %d must be an object", i)
+		}
+		prices[i] = models.LabeledPrice{
+			Label:  cast.ToString(price["label"]),
+			Amount: cast.ToInt(price["amount"]),
+		}
+	}
+	return prices, nil
+}
+
+// Query answers
+
+func (uctx *UpdateContext) createAnswerShippingQuery() func(bool, map[string]interface{}) error {
+	return func(ok bool, options map[string]interface{}) error {
+		if uctx.update.ShippingQuery == nil {
+			return fmt.Errorf("no shipping query to answer")
+		}
+		return uctx.instance.answerShippingQuery(uctx.update.ShippingQuery.ID, ok, options)
+	}
+}
+
+func (instance *BotInstance) createAnswerShippingQuery() func(string, bool, map[string]interface{}) error {
+	return func(shippingQueryID string, ok bool, options map[string]interface{}) error {
+		return instance.answerShippingQuery(shippingQueryID, ok, options)
+	}
+}
+
+func (instance *BotInstance) answerShippingQuery(shippingQueryID string, ok bool, options map[string]interface{}) error {
+	params := &bot.AnswerShippingQueryParams{
+		ShippingQueryID: shippingQueryID,
+		OK:              ok,
+	}
+
+	if options != nil {
+		params.ErrorMessage = cast.ToString(options["errorMessage"])
+		if raw, isSlice := options["shippingOptions"].([]interface{}); isSlice {
+			for i, item := range raw {
+				opt, isMap := item.(map[string]interface{})
+				if !isMap {
+					return fmt.Errorf("shipping option %d must be an object", i)
+				}
+				prices, err := buildLabeledPrices(opt["prices"])
+				if err != nil {
+					return fmt.Errorf("shipping option %d: %w", i, err)
+				}
+				params.ShippingOptions = append(params.ShippingOptions, models.ShippingOption{
+					ID:     cast.ToString(opt["id"]),
+					Title:  cast.ToString(opt["title"]),
+					Prices: prices,
+				})
+			}
+		}
+	}
+
+	_, err := instance.bot.AnswerShippingQuery(instance.ctx, params)
+	return instance.apiError(err)
+}
+
+func (uctx *UpdateContext) createAnswerPreCheckoutQuery() func(bool, string) error {
+	return func(ok bool, errorMessage string) error {
+		if uctx.update.PreCheckoutQuery == nil {
+			return fmt.Errorf("no pre-checkout query to answer")
+		}
+		return uctx.instance.answerPreCheckoutQuery(uctx.update.PreCheckoutQuery.ID, ok, errorMessage)
+	}
+}
+
+func (instance *BotInstance) createAnswerPreCheckoutQuery() func(string, bool, string) error {
+	return func(preCheckoutQueryID string, ok bool, errorMessage string) error {
+		return instance.answerPreCheckoutQuery(preCheckoutQueryID, ok, errorMessage)
+	}
+}
+
+func (instance *BotInstance) answerPreCheckoutQuery(preCheckoutQueryID string, ok bool, errorMessage string) error {
+	_, err := instance.bot.AnswerPreCheckoutQuery(instance.ctx, &bot.AnswerPreCheckoutQueryParams{
+		PreCheckoutQueryID: preCheckoutQueryID,
+		OK:                 ok,
+		ErrorMessage:       errorMessage,
+	})
+	return instance.apiError(err)
+}
+
+// Telegram Stars
+
+func (instance *BotInstance) createRefundStarPayment() func(int64, string) error {
+	return func(userID int64, telegramPaymentChargeID string) error {
+		_, err := instance.bot.RefundStarPayment(instance.ctx, &bot.RefundStarPaymentParams{
+			UserID:                  userID,
+			TelegramPaymentChargeID: telegramPaymentChargeID,
+		})
+		return instance.apiError(err)
+	}
+}
+
+// Converters
+
+func convertShippingAddress(a *models.ShippingAddress) map[string]interface{} {
+	return map[string]interface{}{
+		"countryCode": a.CountryCode,
+		"state":       a.State,
+		"city":        a.City,
+		"streetLine1": a.StreetLine1,
+		"streetLine2": a.StreetLine2,
+		"postCode":    a.PostCode,
+	}
+}
+
+func convertOrderInfo(o *models.OrderInfo) map[string]interface{} {
+	info := map[string]interface{}{
+		"name":        o.Name,
+		"phoneNumber": o.PhoneNumber,
+		"email":       o.Email,
+	}
+	if o.ShippingAddress != nil {
+		info["shippingAddress"] = convertShippingAddress(o.ShippingAddress)
+	}
+	return info
+}
+
+func (uctx *UpdateContext) convertShippingQuery(q *models.ShippingQuery) map[string]interface{} {
+	return map[string]interface{}{
+		"id":              q.ID,
+		"from":            uctx.convertUser(q.From),
+		"invoicePayload":  q.InvoicePayload,
+		"shippingAddress": convertShippingAddress(&q.ShippingAddress),
+	}
+}
+
+func (uctx *UpdateContext) convertPreCheckoutQuery(q *models.PreCheckoutQuery) map[string]interface{} {
+	query := map[string]interface{}{
+		"id":               q.ID,
+		"from":             uctx.convertUser(q.From),
+		"currency":         q.Currency,
+		"totalAmount":      q.TotalAmount,
+		"invoicePayload":   q.InvoicePayload,
+		"shippingOptionId": q.ShippingOptionID,
+	}
+	if q.OrderInfo != nil {
+		query["orderInfo"] = convertOrderInfo(q.OrderInfo)
+	}
+	return query
+}
+
+func convertSuccessfulPayment(p *models.SuccessfulPayment) map[string]interface{} {
+	payment := map[string]interface{}{
+		"currency":                   p.Currency,
+		"totalAmount":                p.TotalAmount,
+		"invoicePayload":             p.InvoicePayload,
+		"subscriptionExpirationDate": p.SubscriptionExpirationDate,
+		"isRecurring":                p.IsRecurring,
+		"isFirstRecurring":           p.IsFirstRecurring,
+		"shippingOptionId":           p.ShippingOptionID,
+		"telegramPaymentChargeId":    p.TelegramPaymentChargeID,
+		"providerPaymentChargeId":    p.ProviderPaymentChargeID,
+	}
+	if p.OrderInfo != nil {
+		payment["orderInfo"] = convertOrderInfo(p.OrderInfo)
+	}
+	return payment
+}
+
+func convertRefundedPayment(p *models.RefundedPayment) map[string]interface{} {
+	return map[string]interface{}{
+		"currency":                p.Currency,
+		"totalAmount":             p.TotalAmount,
+		"invoicePayload":          p.InvoicePayload,
+		"telegramPaymentChargeId": p.TelegramPaymentChargeID,
+		"providerPaymentChargeId": p.ProviderPaymentChargeID,
+	}
+}
+
+func convertInvoice(i *models.Invoice) map[string]interface{} {
+	return map[string]interface{}{
+		"title":          i.Title,
+		"description":    i.Description,
+		"startParameter": i.StartParameter,
+		"currency":       i.Currency,
+		"totalAmount":    i.TotalAmount,
+	}
+}
@@ -4,14 +4,15 @@ package main
 
 import (
 	"context"
-	"crypto/tls"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/dop251/goja"
 	"github.com/go-telegram/bot"
 	"github.com/go-telegram/bot/models"
+	"github.com/spf13/cast"
 )
 
 func (p *TelegramPlugin) Name() string {
@@ -36,13 +37,26 @@ func (p *TelegramPlugin) URL() string {
 
 func (p *TelegramPlugin) Init(config map[string]interface{}) error {
 	p.bots = make(map[string]*BotInstance)
+	p.loops = make(map[*goja.Runtime]*jsLoop)
+	p.errorHandlers = make(map[*goja.Runtime]goja.Callable)
+	p.transports = make(map[string]*http.Transport)
 	p.initialized = true
-	p.skipTLSVerify = true // Default to true
+	p.queueSize = defaultQueueSize
+	p.lanes = defaultLanes
 	if path, ok := config["storage_path"].(string); ok {
 		p.storagePath = path
 	}
-	if skipTLS, ok := config["skipTLSVerify"].(bool); ok {
-		p.skipTLSVerify = skipTLS
+	if queueSize := cast.ToInt(config["queueSize"]); queueSize > 0 {
+		p.queueSize = queueSize
+	}
+	if lanes := cast.ToInt(config["lanes"]); lanes > 0 {
+		p.lanes = lanes
+	}
+
+	// Build the shared transport now so a bad CA bundle or proxy fails early
+	p.http = parseHTTPConfig(config)
+	if _, err := p.transportFor(p.http.proxy); err != nil {
+		return err
 	}
 	return nil
 }
@@ -52,100 +66,377 @@ func (p *TelegramPlugin) RegisterModule(runtime *goja.Runtime) error {
 		"startBot": p.createStartBot(runtime),
 		"stopBot":  p.stopBot,
 		"stopAll":  p.stopAll,
+		"catch":    p.createCatch(runtime),
 	})
 }
 
 func (p *TelegramPlugin) Shutdown() error {
 	p.stopAll()
+
+	p.mu.Lock()
+	for rt, loop := range p.loops {
+		loop.Stop()
+		delete(p.loops, rt)
+		delete(p.errorHandlers, rt)
+	}
+	p.mu.Unlock()
+	p.closeTransports()
+
 	p.initialized = false
 	return nil
 }
 
 // createStartBot creates the startBot function with runtime context
-func (p *TelegramPlugin) createStartBot(runtime *goja.Runtime) func(string, goja.Callable) error {
-	return func(token string, callback goja.Callable) error {
-		return p.startBot(runtime, token, callback)
+func (p *TelegramPlugin) createStartBot(runtime *goja.Runtime) func(string, goja.Callable, map[string]interface{}) error {
+	return func(token string, callback goja.Callable, options map[string]interface{}) error {
+		cfg, err := parseBotOptions(options)
+		if err != nil {
+			return err
+		}
+		return p.startBot(runtime, token, callback, cfg)
 	}
 }
 
+// parseBotOptions converts startBot options from JS
+func parseBotOptions(options map[string]interface{}) (botOptions, error) {
+	opts := botOptions{
+		mode:   "polling",
+		listen: defaultWebhookListen,
+		path:   defaultWebhookPath,
+	}
+	opts.rateLimit, _ = parseFloodLimits(nil)
+	opts.apiURL = telegramAPIURL
+	if options == nil {
+		return opts, nil
+	}
+
+	if mode := cast.ToString(options["mode"]); mode != "" {
+		opts.mode = mode
+	}
+	opts.webhookURL = cast.ToString(options["url"])
+	opts.secretToken = cast.ToString(options["secretToken"])
+	if listen := cast.ToString(options["listen"]); listen != "" {
+		opts.listen = listen
+	}
+	if path := cast.ToString(options["path"]); path != "" {
+		opts.path = path
+	}
+	session, err := parseSessionOptions(options["session"])
+	if err != nil {
+		return opts, err
+	}
+	opts.session = session
+	opts.dedupWindow = cast.ToInt(options["dedupWindow"])
+	if apiURL := cast.ToString(options["apiUrl"]); apiURL != "" {
+		opts.apiURL = strings.TrimRight(apiURL, "/")
+	}
+	opts.localMode = cast.ToBool(options["localMode"])
+	allowedUpdates, err := parseAllowedUpdates(options["allowedUpdates"])
+	if err != nil {
+		return opts, err
+	}
+	opts.allowedUpdates = allowedUpdates
+	opts.proxy = cast.ToString(options["proxy"])
+	opts.requestTimeout = time.Duration(cast.ToInt(options["requestTimeout"])) * time.Second
+	opts.pollTimeout = time.Duration(cast.ToInt(options["pollTimeout"])) * time.Second
+	rateLimit, err := parseFloodLimits(options["rateLimit"])
+	if err != nil {
+		return opts, err
+	}
+	opts.rateLimit = rateLimit
+
+	switch opts.mode {
+	case "polling", "webhook":
+	default:
+		return opts, fmt.Errorf("unknown mode %q, expected \"polling\" or \"webhook\"", opts.mode)
+	}
+	return opts, nil
+}
+
 // startBot starts a new Telegram bot with the given token
-func (p *TelegramPlugin) startBot(runtime *goja.Runtime, token string, callback goja.Callable) error {
+func (p *TelegramPlugin) startBot(runtime *goja.Runtime, token string, callback goja.Callable, cfg botOptions) error {
 	p.mu.Lock()
-	defer p.mu.Unlock()
 
-	// Stop existing bot with same token
-	if existing, ok := p.bots[token]; ok {
-		existing.cancel()
+	// Stop existing bot with same token. Its broadcasts are restored below, so
+	// let them finish in-flight sends and save their progress first; the
+	// lock is released meanwhile since that may take a while.
+	existing := p.bots[token]
+	for existing != nil {
+		p.mu.Unlock()
+		existing.stop()
+		existing.waitBroadcasts(broadcastStopTimeout)
+		p.mu.Lock()
+		// startBot may have been called again for this token meanwhile
+		current := p.bots[token]
+		if current == nil || current == existing {
+			break
+		}
+		existing = current
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 
 	instance := &BotInstance{
-		ctx:         ctx,
-		cancel:      cancel,
-		runtime:     runtime,
-		handlers:    make(map[string]goja.Callable),
-		callbacks:   make(map[string]goja.Callable),
-		storagePath: p.storagePath,
-		plugin:      p,
+		ctx:                ctx,
+		cancel:             cancel,
+		token:              token,
+		apiURL:             cfg.apiURL,
+		localMode:          cfg.localMode,
+		runtime:            runtime,
+		loop:               p.loopFor(runtime),
+		handlers:           newRouter(),
+		callbacks:          newRouter(),
+		inlineQueries:      newRouter(),
+		shippingQueries:    newRouter(),
+		preCheckoutQueries: newRouter(),
+		events:             make(map[string]goja.Callable),
+		broadcasts:         make(map[string]*broadcastJob),
+		storagePath:        p.storagePath,
+		plugin:             p,
+		ready:              make(chan struct{}),
+	}
+	instance.id, _, _ = strings.Cut(token, ":")
+	instance.scenes = newSceneManager(instance.dataPath(scenesFile))
+	instance.tracker = newUpdateTracker(instance.dataPath(offsetFile), cfg.dedupWindow)
+	instance.restoreBroadcasts()
+	if cfg.session != nil {
+		sessions, err := instance.newSessionStore(cfg.session)
+		if err != nil {
+			cancel()
+			p.releaseLoop(runtime)
+			p.mu.Unlock()
+			return err
+		}
+		instance.sessions = sessions
+		instance.sessionConfig = cfg.session
 	}
+	instance.lanes = newUpdateLanes(ctx, p.lanes, instance.processUpdate)
 
-	// Create bot options with default handler
+	// Create bot options with default handler. Handlers run synchronously in
+	// the library's worker so updates reach the lanes in arrival order.
 	opts := []bot.Option{
 		bot.WithDefaultHandler(func(ctx context.Context, b *bot.Bot, update *models.Update) {
 			instance.handleUpdate(ctx, b, update)
 		}),
+		bot.WithNotAsyncHandlers(),
+		bot.WithServerURL(cfg.apiURL),
 	}
 
-	// Add custom HTTP client if TLS verification should be skipped
-	if p.skipTLSVerify {
-		transport := &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		}
-		httpClient := &http.Client{
-			Transport: transport,
-			Timeout:   30 * time.Second,
-		}
-		opts = append(opts, bot.WithHTTPClient(60*time.Second, httpClient))
+	// Bots share pooled transports; a bot with its own proxy gets the pool of
+	// that proxy
+	proxy := cfg.proxy
+	if proxy == "" {
+		proxy = p.http.proxy
+	}
+	transport, err := p.transportFor(proxy)
+	if err != nil {
+		cancel()
+		p.releaseLoop(runtime)
+		p.mu.Unlock()
+		return err
 	}
+	requestTimeout := p.http.requestTimeout
+	if cfg.requestTimeout > 0 {
+		requestTimeout = cfg.requestTimeout
+	}
+	instance.pollTimeout = p.http.pollTimeout
+	if cfg.pollTimeout > 0 {
+		instance.pollTimeout = cfg.pollTimeout
+	}
+	instance.httpClient = &http.Client{Transport: transport, Timeout: requestTimeout}
+	instance.pollClient = &http.Client{Transport: transport, Timeout: instance.pollTimeout + requestTimeout}
+
+	// API calls go through flood control, which paces messages and retries
+	// rate-limited and transient failures
+	opts = append(opts, bot.WithHTTPClient(instance.pollTimeout, newFloodControl(instance.httpClient, cfg.rateLimit, instance.loop.onLoop)))
 
 	b, err := bot.New(token, opts...)
 	if err != nil {
 		cancel()
+		p.releaseLoop(runtime)
+		p.mu.Unlock()
 		return fmt.Errorf("failed to create bot: %w", err)
 	}
 
 	instance.bot = b
 	p.bots[token] = instance
+	if existing != nil {
+		// The replaced bot may have been the last one of a reloaded script
+		p.releaseLoop(existing.runtime)
+	}
+
+	// The setup callback may call back into the plugin (stopBot, startBot),
+	// so it must run without holding the plugin lock
+	p.mu.Unlock()
+
+	// Remember our username so "/cmd@otherbot" is not dispatched to us
+	if me, err := b.GetMe(ctx); err == nil {
+		instance.username = me.Username
+	}
 
 	// Create instance object for JavaScript
 	instanceObj := p.createInstanceObject(runtime, instance)
 
-	// Call the setup callback
+	// Call the setup callback on the runtime's event loop
 	if callback != nil {
-		_, err := callback(goja.Undefined(), runtime.ToValue(instanceObj))
+		var setupErr error
+		runErr := instance.loop.Run(ctx, func() {
+			_, setupErr = callback(goja.Undefined(), runtime.ToValue(instanceObj))
+		})
+		if runErr != nil {
+			setupErr = runErr
+		}
+		if setupErr != nil {
+			p.discardBot(token, instance)
+			return fmt.Errorf("setup callback failed: %w", setupErr)
+		}
+	}
+
+	// Only ask Telegram for updates that have a handler, unless the types
+	// were listed explicitly
+	instance.allowedUpdates = cfg.allowedUpdates
+	if instance.allowedUpdates == nil {
+		err := instance.loop.Run(ctx, func() {
+			instance.allowedUpdates = instance.handledUpdateTypes()
+		})
 		if err != nil {
-			cancel()
-			delete(p.bots, token)
-			return fmt.Errorf("setup callback failed: %w", err)
+			p.discardBot(token, instance)
+			return err
 		}
 	}
 
-	// Start the bot in background
-	go func() {
-		b.Start(ctx)
-	}()
+	// Start receiving updates in background
+	if cfg.mode == "webhook" {
+		if err := instance.startWebhook(cfg); err != nil {
+			p.discardBot(token, instance)
+			return err
+		}
+	} else {
+		go instance.poll()
+	}
+
+	// Handlers must not run while the calling script is still executing
+	if err := instance.releaseAfterCaller(); err != nil {
+		p.discardBot(token, instance)
+		return err
+	}
 
 	return nil
 }
 
+// releaseAfterCaller lets handlers run and continues broadcasts interrupted
+// by a restart once the JS that called startBot has left the runtime;
+// updates received meanwhile wait in their lanes. Called from a job (e.g.
+// a handler restarting the bot), that is right away, since handlers queue
+// behind the job anyway. A script running off the loop is followed through
+// the runtime's promise job queue, which goja drains when the script's
+// top-level call returns.
+func (instance *BotInstance) releaseAfterCaller() error {
+	release := func() {
+		close(instance.ready)
+		instance.resumeBroadcasts()
+	}
+	if instance.loop.onLoop() {
+		release()
+		return nil
+	}
+
+	runtime := instance.runtime
+	var err error
+	runErr := instance.loop.Run(instance.ctx, func() {
+		promise, resolve, _ := runtime.NewPromise()
+		then, _ := goja.AssertFunction(runtime.ToValue(promise).ToObject(runtime).Get("then"))
+		_, err = then(runtime.ToValue(promise), runtime.ToValue(func(goja.FunctionCall) goja.Value {
+			release()
+			return goja.Undefined()
+		}))
+		resolve(goja.Undefined())
+	})
+	if runErr != nil {
+		return runErr
+	}
+	return err
+}
+
+// waitReady blocks until the bot's handlers may run, see releaseAfterCaller
+func (instance *BotInstance) waitReady() error {
+	select {
+	case <-instance.ready:
+		return nil
+	case <-instance.ctx.Done():
+		return instance.ctx.Err()
+	}
+}
+
+// discardBot stops a bot that failed to start and forgets it, unless it has
+// already been replaced by a newer instance
+func (p *TelegramPlugin) discardBot(token string, instance *BotInstance) {
+	instance.stop()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.bots[token] == instance {
+		delete(p.bots, token)
+		p.releaseLoop(instance.runtime)
+	}
+}
+
+// loopFor returns the event loop bound to runtime, creating it on first use.
+// Must be called with p.mu held.
+func (p *TelegramPlugin) loopFor(runtime *goja.Runtime) *jsLoop {
+	if loop, ok := p.loops[runtime]; ok {
+		return loop
+	}
+	loop := newJSLoop(runtime, p.queueSize)
+	p.loops[runtime] = loop
+	return loop
+}
+
+// releaseLoop stops the event loop of runtime and forgets its error handler
+// once no bot runs on it anymore. Reloading a script creates a new runtime,
+// so this is what lets the old one go. Must be called with p.mu held.
+func (p *TelegramPlugin) releaseLoop(runtime *goja.Runtime) {
+	for _, instance := range p.bots {
+		if instance.runtime == runtime {
+			return
+		}
+	}
+	loop, ok := p.loops[runtime]
+	if ok && loop.onLoop() {
+		// Called from a job, e.g. stopBot in a handler: the loop is still
+		// running it and the job may start another bot on the runtime, so
+		// check again once it has returned
+		go func() {
+			loop.Run(context.Background(), func() {})
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			p.releaseLoop(runtime)
+		}()
+		return
+	}
+	if ok {
+		loop.Stop()
+		delete(p.loops, runtime)
+	}
+	delete(p.errorHandlers, runtime)
+}
+
 // createInstanceObject creates the $instance object for JavaScript
 func (p *TelegramPlugin) createInstanceObject(runtime *goja.Runtime, instance *BotInstance) map[string]interface{} {
 	return map[string]interface{}{
 		// Handler registration
-		"handle":         instance.createHandle(),
-		"handleCallback": instance.createHandleCallback(),
-		"handleDefault":  instance.createHandleDefault(),
+		"handle":                 instance.createHandle(),
+		"hears":                  instance.createHandle(),
+		"handleCallback":         instance.createHandleCallback(),
+		"handleInlineQuery":      instance.createHandleInlineQuery(),
+		"handleShippingQuery":    instance.createHandleShippingQuery(),
+		"handlePreCheckoutQuery": instance.createHandlePreCheckoutQuery(),
+		"handleDefault":          instance.createHandleDefault(),
+		"on":                     instance.createOn(),
+		"use":                    instance.createUse(),
+		"routes":                 instance.createRoutes(),
+		"scene":                  instance.createScene(),
+		"catch":                  instance.createCatch(),
 
 		// Message sending
 		"sendMessage":  instance.createSendMessage(),
@@ -164,35 +455,96 @@ func (p *TelegramPlugin) createInstanceObject(runtime *goja.Runtime, instance *B
 		// Callback answers
 		"answerCallback": instance.createAnswerCallback(),
 
+		// Inline mode
+		"answerInlineQuery": instance.createAnswerInlineQuery(),
+		"inline":            createInlineBuilders(),
+
+		// Payments
+		"sendInvoice":            instance.createSendInvoice(),
+		"createInvoiceLink":      instance.createCreateInvoiceLink(),
+		"answerShippingQuery":    instance.createAnswerShippingQuery(),
+		"answerPreCheckoutQuery": instance.createAnswerPreCheckoutQuery(),
+		"refundStarPayment":      instance.createRefundStarPayment(),
+
+		// Broadcasts
+		"broadcast":    instance.createBroadcast(),
+		"getBroadcast": instance.createGetBroadcast(),
+
 		// Bot info
 		"getMe": instance.createGetMe(),
 
+		// Bot API server migration
+		"logOut": instance.createLogOut(),
+		"close":  instance.createClose(),
+
 		// Utilities
 		"getChatMember": instance.createGetChatMember(),
 		"getFile":       instance.createGetFile(),
+		"downloadFile":  instance.createDownloadFile(),
+
+		// Join requests
+		"approveChatJoinRequest": instance.createApproveChatJoinRequest(),
+		"declineChatJoinRequest": instance.createDeclineChatJoinRequest(),
+
+		// Chat administration
+		"banChatMember":                   instance.createBanChatMember(),
+		"unbanChatMember":                 instance.createUnbanChatMember(),
+		"restrictChatMember":              instance.createRestrictChatMember(),
+		"promoteChatMember":               instance.createPromoteChatMember(),
+		"setChatAdministratorCustomTitle": instance.createSetChatAdministratorCustomTitle(),
+		"banChatSenderChat":               instance.createBanChatSenderChat(),
+		"unbanChatSenderChat":             instance.createUnbanChatSenderChat(),
+		"setChatPermissions":              instance.createSetChatPermissions(),
+		"getChatAdministrators":           instance.createGetChatAdministrators(),
+		"getChatMemberCount":              instance.createGetChatMemberCount(),
+		"leaveChat":                       instance.createLeaveChat(),
 	}
 }
 
 // stopBot stops a bot by token
 func (p *TelegramPlugin) stopBot(token string) {
 	p.mu.Lock()
-	defer p.mu.Unlock()
+	instance, ok := p.bots[token]
+	delete(p.bots, token)
+	p.mu.Unlock()
 
-	if instance, ok := p.bots[token]; ok {
-		instance.cancel()
-		delete(p.bots, token)
+	if ok {
+		p.stopInstances(instance)
 	}
 }
 
 // stopAll stops all bots
 func (p *TelegramPlugin) stopAll() {
 	p.mu.Lock()
-	defer p.mu.Unlock()
-
+	instances := make([]*BotInstance, 0, len(p.bots))
 	for token, instance := range p.bots {
-		instance.cancel()
+		instances = append(instances, instance)
 		delete(p.bots, token)
 	}
+	p.mu.Unlock()
+
+	p.stopInstances(instances...)
+}
+
+// stopInstances shuts down bots already removed from p.bots, then releases
+// their loops. Tearing a webhook down waits on Telegram, so it runs without
+// holding the plugin lock.
+func (p *TelegramPlugin) stopInstances(instances ...*BotInstance) {
+	for _, instance := range instances {
+		instance.stop()
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, instance := range instances {
+		p.releaseLoop(instance.runtime)
+	}
+}
+
+// stop shuts the bot down and releases its webhook, if any
+func (instance *BotInstance) stop() {
+	instance.stopWebhook()
+	instance.cancel()
 }
 
 // NewPlugin is the exported function that returns a new plugin instance
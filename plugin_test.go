@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+// newFakeBotAPI serves getMe and hands out a single /start update; further
+// getUpdates calls stay empty
+func newFakeBotAPI(t *testing.T) *httptest.Server {
+	t.Helper()
+	delivered := make(chan struct{}, 1)
+	delivered <- struct{}{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/getMe"):
+			io.WriteString(w, `{"ok": true, "result": {"id": 123, "is_bot": true, "first_name": "Test", "username": "testbot"}}`)
+		case strings.HasSuffix(r.URL.Path, "/getUpdates"):
+			select {
+			case <-delivered:
+				io.WriteString(w, `{"ok": true, "result": [{"update_id": 1, "message": {"message_id": 1, "date": 0, "chat": {"id": 7, "type": "private"}, "from": {"id": 7, "is_bot": false, "first_name": "U"}, "text": "/start"}}]}`)
+			case <-r.Context().Done():
+			case <-time.After(100 * time.Millisecond):
+				io.WriteString(w, `{"ok": true, "result": []}`)
+			}
+		default:
+			io.WriteString(w, `{"ok": true, "result": true}`)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newTestPlugin(t *testing.T) *TelegramPlugin {
+	t.Helper()
+	p := &TelegramPlugin{}
+	if err := p.Init(map[string]interface{}{"storage_path": t.TempDir()}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { p.Shutdown() })
+	return p
+}
+
+func TestStartBotHoldsUpdatesUntilScriptReturns(t *testing.T) {
+	server := newFakeBotAPI(t)
+	p := newTestPlugin(t)
+	runtime := goja.New()
+	if err := p.RegisterModule(runtime); err != nil {
+		t.Fatal(err)
+	}
+
+	received := make(chan bool, 1)
+	runtime.Set("received", func(finished bool) { received <- finished })
+	runtime.Set("apiUrl", server.URL)
+
+	// The script keeps using the runtime after startBot returns, while the
+	// update is already waiting. Like every call into the runtime it runs on
+	// the runtime's event loop.
+	p.mu.Lock()
+	loop := p.loopFor(runtime)
+	p.mu.Unlock()
+	var err error
+	runErr := loop.Run(context.Background(), func() {
+		_, err = runtime.RunString(`
+			var finished = false;
+			$telegram.startBot("123:test", function(bot) {
+				bot.handle("/start", function(ctx) { received(finished); });
+			}, {apiUrl: apiUrl, pollTimeout: 1});
+			var deadline = Date.now() + 200, work = [];
+			while (Date.now() < deadline) {
+				work.push({n: work.length});
+			}
+			finished = true;
+		`)
+	})
+	if runErr != nil {
+		t.Fatal(runErr)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case finished := <-received:
+		if !finished {
+			t.Fatal("handler ran while the script was still executing")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("update was not delivered")
+	}
+}
+
+func TestRestartBotFromJobKeepsLoop(t *testing.T) {
+	server := newFakeBotAPI(t)
+	p := newTestPlugin(t)
+	runtime := goja.New()
+	if err := p.RegisterModule(runtime); err != nil {
+		t.Fatal(err)
+	}
+	runtime.Set("apiUrl", server.URL)
+
+	p.mu.Lock()
+	loop := p.loopFor(runtime)
+	p.mu.Unlock()
+	runScript := func(script string) {
+		t.Helper()
+		var err error
+		if runErr := loop.Run(context.Background(), func() { _, err = runtime.RunString(script) }); runErr != nil {
+			t.Fatal(runErr)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	runScript(`$telegram.startBot("123:test", null, {apiUrl: apiUrl, pollTimeout: 1});`)
+	// Restarting from a job, as a handler would, must keep the loop it runs on
+	runScript(`
+		$telegram.stopBot("123:test");
+		$telegram.startBot("123:test", null, {apiUrl: apiUrl, pollTimeout: 1});
+	`)
+	runScript(``)
+
+	p.mu.Lock()
+	current, count := p.loops[runtime], len(p.loops)
+	p.mu.Unlock()
+	if current != loop || count != 1 {
+		t.Fatalf("runtime has %d loops, original kept: %v", count, current == loop)
+	}
+
+	// Once no bot is left, the loop is released after the job returns
+	runScript(`$telegram.stopBot("123:test");`)
+	deadline := time.Now().Add(time.Second)
+	for {
+		p.mu.Lock()
+		_, ok := p.loops[runtime]
+		p.mu.Unlock()
+		if !ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("loop was not released")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := loop.Run(context.Background(), func() {}); !errors.Is(err, errLoopStopped) {
+		t.Fatalf("released loop still runs jobs: %v", err)
+	}
+}
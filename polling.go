@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/go-telegram/bot/models"
+)
+
+const (
+	telegramAPIURL      = "https://api.telegram.org"
+	pollLimit           = 100
+	maxPollErrorBackoff = 5 * time.Second
+)
+
+// getUpdatesResponse is the Bot API response to getUpdates
+type getUpdatesResponse struct {
+	OK          bool             `json:"ok"`
+	Result      []*models.Update `json:"result"`
+	Description string           `json:"description"`
+	ErrorCode   int              `json:"error_code"`
+	Parameters  struct {
+		RetryAfter int `json:"retry_after"`
+	} `json:"parameters"`
+}
+
+// poll receives updates with long polling. Unlike the library's poller, the
+// next batch is only requested (which confirms the previous one to Telegram)
+// after every update of the current batch has been handled, so updates that
+// were in flight when the process died are delivered again after a restart.
+func (instance *BotInstance) poll() {
+	ctx := instance.ctx
+	offset := instance.tracker.offset()
+	var backoff time.Duration
+
+	for {
+		if backoff > 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+		}
+
+		updates, retryAfter, err := instance.getUpdates(ctx, offset)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			fmt.Printf("[ERROR] Failed to get updates: %v\n", err)
+			backoff = nextPollBackoff(backoff, retryAfter)
+			continue
+		}
+		backoff = 0
+
+		for _, update := range updates {
+			instance.handleUpdate(ctx, instance.bot, update)
+			offset = update.ID + 1
+		}
+		if err := instance.tracker.wait(ctx); err != nil {
+			return
+		}
+	}
+}
+
+// getUpdates requests the next batch of updates starting at offset
+func (instance *BotInstance) getUpdates(ctx context.Context, offset int64) ([]*models.Update, time.Duration, error) {
+	params := map[string]interface{}{
+		"timeout": int(instance.pollTimeout / time.Second),
+		"limit":   pollLimit,
+	}
+	if offset > 0 {
+		params["offset"] = offset
+	}
+	if instance.allowedUpdates != nil {
+		params["allowed_updates"] = instance.allowedUpdates
+	}
+	body, err := json.Marshal(params)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	endpoint := instance.apiURL + "/bot" + instance.token + "/getUpdates"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := instance.pollClient.Do(req)
+	if err != nil {
+		// The URL contains the token, keep it out of the logs
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	var result getUpdatesResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, 0, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if !result.OK {
+		retryAfter := time.Duration(result.Parameters.RetryAfter) * time.Second
+		return nil, retryAfter, fmt.Errorf("%d %s", result.ErrorCode, result.Description)
+	}
+	return result.Result, 0, nil
+}
+
+// nextPollBackoff doubles the delay after consecutive errors, honoring the
+// server's retry_after hint
+func nextPollBackoff(current, retryAfter time.Duration) time.Duration {
+	if retryAfter > 0 {
+		return retryAfter
+	}
+	if current == 0 {
+		return 100 * time.Millisecond
+	}
+	current *= 2
+	if current > maxPollErrorBackoff {
+		return maxPollErrorBackoff
+	}
+	return current
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/dop251/goja"
+)
+
+// routeKind describes how a route pattern is matched
+type routeKind int
+
+const (
+	routeExact   routeKind = iota // text equals pattern
+	routeCommand                  // "/cmd", also matches "/cmd args" and "/cmd@bot"
+	routePrefix                   // "data*", matches by prefix
+	routeParams                   // "product:{id}", captures named segments
+	routeRegex                    // JS RegExp
+)
+
+func (k routeKind) String() string {
+	switch k {
+	case routeExact:
+		return "exact"
+	case routeCommand:
+		return "command"
+	case routePrefix:
+		return "prefix"
+	case routeParams:
+		return "params"
+	case routeRegex:
+		return "regex"
+	}
+	return "unknown"
+}
+
+// route is a single registered handler
+type route struct {
+	kind    routeKind
+	pattern string
+	regex   *regexp.Regexp
+	handler goja.Callable
+}
+
+// routeMatch is the result of a successful route lookup
+type routeMatch struct {
+	handler goja.Callable
+	match   []string
+	params  map[string]string
+}
+
+// router keeps routes in a deterministic resolution order:
+//
+//  1. exact text matches
+//  2. prefix matches ("/command" and "prefix*"), longest prefix first
+//  3. route-style patterns and RegExps, in registration order
+//
+// Ties within a tier are broken by registration order.
+type router struct {
+	routes  []*route
+	ordered []*route
+}
+
+func newRouter() *router {
+	return &router{}
+}
+
+// add registers a route. Registering the same pattern again replaces the
+// handler but keeps the original position.
+func (r *router) add(rt *route) {
+	replaced := false
+	for i, existing := range r.routes {
+		if existing.kind == rt.kind && existing.pattern == rt.pattern {
+			r.routes[i] = rt
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		r.routes = append(r.routes, rt)
+	}
+
+	r.ordered = make([]*route, len(r.routes))
+	copy(r.ordered, r.routes)
+	sort.SliceStable(r.ordered, func(i, j int) bool {
+		ti, tj := r.ordered[i].tier(), r.ordered[j].tier()
+		if ti != tj {
+			return ti < tj
+		}
+		if ti == 1 {
+			return r.ordered[i].prefixLen() > r.ordered[j].prefixLen()
+		}
+		return false
+	})
+}
+
+// match returns the first route in resolution order that matches text
+func (r *router) match(text string) *routeMatch {
+	for _, rt := range r.ordered {
+		if m := rt.match(text); m != nil {
+			return m
+		}
+	}
+	return nil
+}
+
+// describe lists the routes in resolution order for bot.routes()
+func (r *router) describe(source string) []map[string]interface{} {
+	result := make([]map[string]interface{}, len(r.ordered))
+	for i, rt := range r.ordered {
+		result[i] = map[string]interface{}{
+			"source":  source,
+			"kind":    rt.kind.String(),
+			"pattern": rt.pattern,
+		}
+	}
+	return result
+}
+
+// tier returns the precedence group of a route
+func (rt *route) tier() int {
+	switch rt.kind {
+	case routeExact:
+		return 0
+	case routeCommand, routePrefix:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// prefixLen returns the length of the literal prefix of prefix routes
+func (rt *route) prefixLen() int {
+	return len(strings.TrimSuffix(rt.pattern, "*"))
+}
+
+func (rt *route) match(text string) *routeMatch {
+	switch rt.kind {
+	case routeExact:
+		if text == rt.pattern {
+			return &routeMatch{handler: rt.handler}
+		}
+	case routeCommand:
+		// The command ends where parseCommand ends it: at whitespace or
+		// the @botname mention
+		if rest, ok := strings.CutPrefix(text, rt.pattern); ok {
+			next, _ := utf8.DecodeRuneInString(rest)
+			if rest == "" || next == '@' || unicode.IsSpace(next) {
+				return &routeMatch{handler: rt.handler}
+			}
+		}
+	case routePrefix:
+		if strings.HasPrefix(text, strings.TrimSuffix(rt.pattern, "*")) {
+			return &routeMatch{handler: rt.handler}
+		}
+	case routeParams, routeRegex:
+		groups := rt.regex.FindStringSubmatch(text)
+		if groups == nil {
+			return nil
+		}
+		params := make(map[string]string)
+		for i, name := range rt.regex.SubexpNames() {
+			if name != "" && i < len(groups) {
+				params[name] = groups[i]
+			}
+		}
+		return &routeMatch{handler: rt.handler, match: groups, params: params}
+	}
+	return nil
+}
+
+// paramPattern finds {name} placeholders in route-style patterns
+var paramPattern = regexp.MustCompile(`\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// newRoute builds a route from a JS pattern: a RegExp, a route-style string
+// with {param} placeholders, a "prefix*" string, a "/command" or exact text
+func newRoute(pattern goja.Value, handler goja.Callable) (*route, error) {
+	if obj, ok := pattern.(*goja.Object); ok && obj.ClassName() == "RegExp" {
+		re, err := compileJSRegExp(obj)
+		if err != nil {
+			return nil, err
+		}
+		return &route{kind: routeRegex, pattern: obj.String(), regex: re, handler: handler}, nil
+	}
+
+	text := pattern.String()
+	switch {
+	case paramPattern.MatchString(text):
+		re, err := compileParamsPattern(text)
+		if err != nil {
+			return nil, err
+		}
+		return &route{kind: routeParams, pattern: text, regex: re, handler: handler}, nil
+	case strings.HasSuffix(text, "*"):
+		return &route{kind: routePrefix, pattern: text, handler: handler}, nil
+	case strings.HasPrefix(text, "/"):
+		return &route{kind: routeCommand, pattern: text, handler: handler}, nil
+	default:
+		return &route{kind: routeExact, pattern: text, handler: handler}, nil
+	}
+}
+
+// compileJSRegExp converts a JS RegExp into a Go regexp. Only the i, m and s
+// flags have an equivalent; g, y and u are ignored.
+func compileJSRegExp(obj *goja.Object) (*regexp.Regexp, error) {
+	source := obj.Get("source").String()
+	flags := obj.Get("flags").String()
+
+	var prefix string
+	for _, flag := range flags {
+		switch flag {
+		case 'i', 'm', 's':
+			prefix += string(flag)
+		}
+	}
+	if prefix != "" {
+		source = "(?" + prefix + ")" + source
+	}
+
+	re, err := regexp.Compile(source)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported regular expression %s: %w", obj.String(), err)
+	}
+	return re, nil
+}
+
+// compileParamsPattern turns "product:{id}:page:{n}" into an anchored regexp
+// with a named group per placeholder
+func compileParamsPattern(pattern string) (*regexp.Regexp, error) {
+	var sb strings.Builder
+	sb.WriteString("^")
+	last := 0
+	for _, loc := range paramPattern.FindAllStringSubmatchIndex(pattern, -1) {
+		sb.WriteString(regexp.QuoteMeta(pattern[last:loc[0]]))
+		sb.WriteString("(?P<" + pattern[loc[2]:loc[3]] + ">.+?)")
+		last = loc[1]
+	}
+	sb.WriteString(regexp.QuoteMeta(pattern[last:]))
+	sb.WriteString("$")
+
+	re, err := regexp.Compile(sb.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid route pattern %q: %w", pattern, err)
+	}
+	return re, nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/dop251/goja"
+)
+
+// namedHandler returns a handler that returns name, so tests can tell which
+// route matched
+func namedHandler(rt *goja.Runtime, name string) goja.Callable {
+	return func(goja.Value, ...goja.Value) (goja.Value, error) {
+		return rt.ToValue(name), nil
+	}
+}
+
+func newTestRouter(t *testing.T, rt *goja.Runtime, patterns ...string) *router {
+	t.Helper()
+	r := newRouter()
+	for _, pattern := range patterns {
+		value, err := rt.RunString(pattern)
+		if err != nil {
+			t.Fatalf("pattern %s: %v", pattern, err)
+		}
+		route, err := newRoute(value, namedHandler(rt, pattern))
+		if err != nil {
+			t.Fatalf("pattern %s: %v", pattern, err)
+		}
+		r.add(route)
+	}
+	return r
+}
+
+// matched returns the pattern of the route that matches text, or ""
+func matched(t *testing.T, r *router, text string) string {
+	t.Helper()
+	m := r.match(text)
+	if m == nil {
+		return ""
+	}
+	value, err := m.handler(goja.Undefined())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return value.String()
+}
+
+func TestRouterPrecedence(t *testing.T) {
+	rt := goja.New()
+	// Registered in reverse precedence order on purpose
+	r := newTestRouter(t, rt,
+		`/^buy (\d+)$/i`,
+		`"buy {n}"`,
+		`"buy*"`,
+		`"buy 1*"`,
+		`"/buy"`,
+		`"buy 10"`,
+	)
+
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"buy 10", `"buy 10"`},      // exact beats everything
+		{"buy 12", `"buy 1*"`},      // longest prefix first
+		{"buy 2", `"buy*"`},         // prefixes beat patterns
+		{"/buy 3", `"/buy"`},        // commands are prefixes
+		{"BUY 4", `/^buy (\d+)$/i`}, // patterns in registration order
+		{"sell", ""},
+	}
+	for _, tt := range tests {
+		if got := matched(t, r, tt.text); got != tt.want {
+			t.Errorf("%q matched %s, want %s", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestRouterPatternsInRegistrationOrder(t *testing.T) {
+	rt := goja.New()
+	r := newTestRouter(t, rt, `"item:{id}"`, `/^item:(\d+)$/`)
+
+	m := r.match("item:42")
+	if m == nil {
+		t.Fatal("no match")
+	}
+	if got := matched(t, r, "item:42"); got != `"item:{id}"` {
+		t.Fatalf("matched %s, want the first registered pattern", got)
+	}
+	if m.params["id"] != "42" {
+		t.Fatalf("params %v, want id=42", m.params)
+	}
+}
+
+func TestRouterReplaceKeepsPosition(t *testing.T) {
+	rt := goja.New()
+	r := newTestRouter(t, rt, `"a:{x}"`, `"{y}:b"`)
+
+	value, _ := rt.RunString(`"a:{x}"`)
+	route, err := newRoute(value, namedHandler(rt, "replaced"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.add(route)
+
+	if got := matched(t, r, "a:b"); got != "replaced" {
+		t.Fatalf("matched %s, want the replaced first route", got)
+	}
+	if len(r.routes) != 2 {
+		t.Fatalf("%d routes, want 2", len(r.routes))
+	}
+}
+
+func TestRouterCommandSeparators(t *testing.T) {
+	rt := goja.New()
+	r := newTestRouter(t, rt, `"/start"`)
+
+	for _, text := range []string{"/start", "/start ref_42", "/start\tref_42", "/start\nref_42", "/start@mybot", "/start\u00a0x"} {
+		if got := matched(t, r, text); got != `"/start"` {
+			t.Errorf("%q did not match /start", text)
+		}
+		if cmd := parseCommand(text); cmd == nil || cmd.name != "start" {
+			t.Errorf("%q: parseCommand disagrees with the route", text)
+		}
+	}
+	if got := matched(t, r, "/started"); got != "" {
+		t.Errorf("/started matched %s", got)
+	}
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/spf13/cast"
+)
+
+const (
+	defaultSceneTimeout  = 10 * time.Minute
+	defaultCancelCommand = "/cancel"
+	scenesFile           = "scenes.json"
+)
+
+// scene is a named sequence of wizard steps registered with bot.scene
+type scene struct {
+	name          string
+	steps         []goja.Callable
+	timeout       time.Duration
+	cancelCommand string
+	onCancel      goja.Callable
+}
+
+// sceneState tracks the scene a chat is currently in
+type sceneState struct {
+	Scene     string                 `json:"scene"`
+	Step      int                    `json:"step"`
+	Data      map[string]interface{} `json:"data"`
+	ExpiresAt int64                  `json:"expiresAt,omitempty"`
+}
+
+// sceneManager holds registered scenes and the active scene of every chat.
+// It is only accessed from the event loop.
+type sceneManager struct {
+	scenes map[string]*scene
+	active map[int64]*sceneState
+	path   string
+}
+
+func newSceneManager(path string) *sceneManager {
+	sm := &sceneManager{
+		scenes: make(map[string]*scene),
+		active: make(map[int64]*sceneState),
+		path:   path,
+	}
+	if path != "" {
+		if err := readJSONFile(path, &sm.active); err != nil {
+			fmt.Printf("[ERROR] Failed to restore scenes: %v\n", err)
+		}
+	}
+	return sm
+}
+
+// save persists active scenes when a storage path is configured
+func (sm *sceneManager) save() {
+	if sm.path == "" {
+		return
+	}
+	if err := writeJSONFile(sm.path, sm.active); err != nil {
+		fmt.Printf("[ERROR] Failed to persist scenes: %v\n", err)
+	}
+}
+
+// touch extends the scene's expiry after activity
+func (sm *sceneManager) touch(state *sceneState) {
+	sc := sm.scenes[state.Scene]
+	if sc != nil && sc.timeout > 0 {
+		state.ExpiresAt = time.Now().Add(sc.timeout).Unix()
+	} else {
+		state.ExpiresAt = 0
+	}
+}
+
+// createScene registers a wizard: bot.scene(name, steps, options?)
+func (instance *BotInstance) createScene() func(string, []goja.Callable, map[string]interface{}) error {
+	return func(name string, steps []goja.Callable, options map[string]interface{}) error {
+		if name == "" {
+			return fmt.Errorf("scene name is required")
+		}
+		if len(steps) == 0 {
+			return fmt.Errorf("scene %q has no steps", name)
+		}
+
+		sc := &scene{
+			name:          name,
+			steps:         steps,
+			timeout:       defaultSceneTimeout,
+			cancelCommand: defaultCancelCommand,
+		}
+		if options != nil {
+			if timeout, ok := options["timeout"]; ok {
+				sc.timeout = time.Duration(cast.ToInt64(timeout)) * time.Second
+			}
+			if cancel, ok := options["cancelCommand"]; ok {
+				sc.cancelCommand = cast.ToString(cancel)
+			}
+			if onCancel, ok := goja.AssertFunction(instance.runtime.ToValue(options["onCancel"])); ok {
+				sc.onCancel = onCancel
+			}
+		}
+
+		instance.scenes.scenes[name] = sc
+		return nil
+	}
+}
+
+// sceneHandler returns the handler for a chat that is inside a scene. The
+// second result is false when the update should be routed normally.
+func (instance *BotInstance) sceneHandler(uctx *UpdateContext) (goja.Callable, bool) {
+	// Membership changes, reactions and the like are not scene input
+	if uctx.getMessage() == nil && uctx.update.CallbackQuery == nil {
+		return nil, false
+	}
+	chatID := uctx.getChatID()
+	if chatID == 0 {
+		return nil, false
+	}
+	sm := instance.scenes
+	state, ok := sm.active[chatID]
+	if !ok {
+		return nil, false
+	}
+
+	sc := sm.scenes[state.Scene]
+	if sc == nil || state.Step >= len(sc.steps) ||
+		(state.ExpiresAt > 0 && time.Now().Unix() > state.ExpiresAt) {
+		delete(sm.active, chatID)
+		sm.save()
+		return nil, false
+	}
+
+	if msg := uctx.getMessage(); msg != nil {
+		if cmd := parseCommand(msg.Text); cmd != nil {
+			// Commands addressed to another bot in a group are not ours, so
+			// they neither cancel the scene nor count as step input
+			if !cmd.addressedTo(instance.username) {
+				return nil, false
+			}
+			if sc.cancelCommand != "" && "/"+cmd.name == sc.cancelCommand {
+				delete(sm.active, chatID)
+				sm.save()
+				return sc.onCancel, true
+			}
+		}
+	}
+
+	uctx.scene = state
+	return sc.steps[state.Step], true
+}
+
+// afterScene persists scene changes made by a step handler
+func (instance *BotInstance) afterScene(uctx *UpdateContext) {
+	chatID := uctx.getChatID()
+	if state, ok := instance.scenes.active[chatID]; ok {
+		instance.scenes.touch(state)
+	}
+	instance.scenes.save()
+}
+
+// Context scene methods
+
+func (uctx *UpdateContext) createEnter() func(string, map[string]interface{}) error {
+	return func(name string, data map[string]interface{}) error {
+		sm := uctx.instance.scenes
+		sc, ok := sm.scenes[name]
+		if !ok {
+			return fmt.Errorf("unknown scene %q", name)
+		}
+		chatID := uctx.getChatID()
+		if chatID == 0 {
+			return fmt.Errorf("no chat ID available")
+		}
+		if data == nil {
+			data = make(map[string]interface{})
+		}
+
+		state := &sceneState{Scene: name, Data: data}
+		sm.touch(state)
+		sm.active[chatID] = state
+		sm.save()
+		uctx.scene = state
+
+		// The first step runs right away, typically to ask the first question
+		ctxObj := uctx.instance.createContextObject(uctx)
+		_, err := sc.steps[0](goja.Undefined(), uctx.runtime.ToValue(ctxObj))
+		return err
+	}
+}
+
+func (uctx *UpdateContext) createLeave() func() {
+	return func() {
+		chatID := uctx.getChatID()
+		if _, ok := uctx.instance.scenes.active[chatID]; ok {
+			delete(uctx.instance.scenes.active, chatID)
+			uctx.instance.scenes.save()
+		}
+		uctx.scene = nil
+	}
+}
+
+// createWizard creates ctx.wizard for moving between scene steps
+func (uctx *UpdateContext) createWizard() map[string]interface{} {
+	wizard := map[string]interface{}{
+		"next": func() {
+			uctx.selectStep(uctx.currentStep() + 1)
+		},
+		"back": func() {
+			uctx.selectStep(uctx.currentStep() - 1)
+		},
+		"selectStep": func(step int) {
+			uctx.selectStep(step)
+		},
+	}
+	if uctx.scene != nil {
+		wizard["scene"] = uctx.scene.Scene
+		wizard["step"] = uctx.scene.Step
+		wizard["state"] = uctx.scene.Data
+	}
+	return wizard
+}
+
+func (uctx *UpdateContext) currentStep() int {
+	if uctx.scene == nil {
+		return 0
+	}
+	return uctx.scene.Step
+}
+
+// selectStep moves the chat's scene to step; stepping past the last step
+// leaves the scene
+func (uctx *UpdateContext) selectStep(step int) {
+	if uctx.scene == nil {
+		return
+	}
+	sm := uctx.instance.scenes
+	sc := sm.scenes[uctx.scene.Scene]
+	if step < 0 {
+		step = 0
+	}
+	if sc == nil || step >= len(sc.steps) {
+		delete(sm.active, uctx.getChatID())
+		uctx.scene = nil
+	} else {
+		uctx.scene.Step = step
+	}
+	sm.save()
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/dop251/goja"
+	"github.com/go-telegram/bot/models"
+)
+
+func newSceneTestBot(t *testing.T) *BotInstance {
+	t.Helper()
+	runtime := goja.New()
+	instance := &BotInstance{
+		runtime:  runtime,
+		username: "testbot",
+		scenes:   newSceneManager(""),
+	}
+	step, err := runtime.RunString(`(function step() {})`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	onCancel, err := runtime.RunString(`(function onCancel() {})`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stepFn, _ := goja.AssertFunction(step)
+	err = instance.createScene()("signup", []goja.Callable{stepFn}, map[string]interface{}{"onCancel": onCancel})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return instance
+}
+
+func sceneMessage(instance *BotInstance, text string) *UpdateContext {
+	return &UpdateContext{
+		instance: instance,
+		runtime:  instance.runtime,
+		update: &models.Update{Message: &models.Message{
+			Chat: models.Chat{ID: 7, Type: "group"},
+			Text: text,
+		}},
+	}
+}
+
+func TestSceneCancelCommand(t *testing.T) {
+	tests := []struct {
+		text      string
+		cancelled bool
+	}{
+		{"/cancel", true},
+		{"/cancel@testbot", true},
+		{"/cancel@TestBot", true},
+		{"/cancel@otherbot", false},
+	}
+	for _, tt := range tests {
+		instance := newSceneTestBot(t)
+		instance.scenes.active[7] = &sceneState{Scene: "signup"}
+
+		handler, inScene := instance.sceneHandler(sceneMessage(instance, tt.text))
+		_, active := instance.scenes.active[7]
+		if tt.cancelled {
+			if !inScene || handler == nil || active {
+				t.Errorf("%q: scene was not cancelled", tt.text)
+			}
+		} else if inScene || !active {
+			t.Errorf("%q: command for another bot was handled by the scene", tt.text)
+		}
+	}
+}
+
+func TestSceneStepReceivesMessages(t *testing.T) {
+	instance := newSceneTestBot(t)
+	instance.scenes.active[7] = &sceneState{Scene: "signup"}
+
+	for _, text := range []string{"Alice", "/start"} {
+		handler, inScene := instance.sceneHandler(sceneMessage(instance, text))
+		if !inScene || handler == nil {
+			t.Fatalf("%q did not reach the scene step", text)
+		}
+		if _, active := instance.scenes.active[7]; !active {
+			t.Fatalf("%q left the scene", text)
+		}
+	}
+}
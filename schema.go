@@ -16,6 +16,7 @@ func (p *TelegramPlugin) GetSchema() schema.ModuleSchema {
 				Params: []schema.ParamSchema{
 					{Name: "token", Type: "string", Description: "Bot token from @BotFather"},
 					{Name: "setup", Type: "(instance: TelegramBotInstance) => void", Description: "Setup callback"},
+					{Name: "options", Type: "StartBotOptions", Description: "Update delivery options (optional)"},
 				},
 			},
 			{
@@ -29,6 +30,13 @@ func (p *TelegramPlugin) GetSchema() schema.ModuleSchema {
 				Name:        "stopAll",
 				Description: "Stop all running bots",
 			},
+			{
+				Name:        "catch",
+				Description: "Handle errors of all bots on this runtime that have no bot.catch handler",
+				Params: []schema.ParamSchema{
+					{Name: "handler", Type: "(err: any, ctx: TelegramContext) => void", Description: "Receives the thrown value (PanicError for Go panics) and the update context"},
+				},
+			},
 		},
 		RawTypes: `interface TelegramUser {
     id: number;
@@ -46,6 +54,7 @@ interface TelegramChat {
     username?: string;
     firstName?: string;
     lastName?: string;
+    isForum?: boolean;
 }
 
 interface TelegramPhotoSize {
@@ -62,6 +71,279 @@ interface TelegramDocument {
     fileName?: string;
     mimeType?: string;
     fileSize?: number;
+    thumbnail?: TelegramPhotoSize;
+}
+
+interface TelegramAnimation {
+    fileId: string;
+    fileUniqueId: string;
+    width: number;
+    height: number;
+    duration: number;
+    fileName?: string;
+    mimeType?: string;
+    fileSize?: number;
+    thumbnail?: TelegramPhotoSize;
+}
+
+interface TelegramAudio {
+    fileId: string;
+    fileUniqueId: string;
+    duration: number;
+    performer?: string;
+    title?: string;
+    fileName?: string;
+    mimeType?: string;
+    fileSize?: number;
+    thumbnail?: TelegramPhotoSize;
+}
+
+interface TelegramVideo {
+    fileId: string;
+    fileUniqueId: string;
+    width: number;
+    height: number;
+    duration: number;
+    fileName?: string;
+    mimeType?: string;
+    fileSize?: number;
+    thumbnail?: TelegramPhotoSize;
+}
+
+interface TelegramVideoNote {
+    fileId: string;
+    fileUniqueId: string;
+    /** Width and height of the round video */
+    length: number;
+    duration: number;
+    fileSize?: number;
+    thumbnail?: TelegramPhotoSize;
+}
+
+interface TelegramVoice {
+    fileId: string;
+    fileUniqueId: string;
+    duration: number;
+    mimeType?: string;
+    fileSize?: number;
+}
+
+interface TelegramPaidMedia {
+    type: "preview" | "photo" | "video";
+    /** For type="preview" */
+    width?: number;
+    height?: number;
+    duration?: number;
+    /** For type="photo" */
+    photo?: TelegramPhotoSize[];
+    /** For type="video" */
+    video?: TelegramVideo;
+}
+
+interface TelegramPaidMediaInfo {
+    starCount: number;
+    paidMedia: TelegramPaidMedia[];
+}
+
+interface TelegramContact {
+    phoneNumber: string;
+    firstName: string;
+    lastName?: string;
+    userId?: number;
+    vcard?: string;
+}
+
+interface TelegramDice {
+    emoji: string;
+    value: number;
+}
+
+interface TelegramMessageEntity {
+    type: string;
+    /** Offset in UTF-16 code units */
+    offset: number;
+    /** Length in UTF-16 code units */
+    length: number;
+    /** For type="text_link" */
+    url?: string;
+    /** For type="text_mention" */
+    user?: TelegramUser;
+    /** For type="pre" */
+    language?: string;
+    /** For type="custom_emoji" */
+    customEmojiId?: string;
+}
+
+interface TelegramPollOption {
+    text: string;
+    textEntities: TelegramMessageEntity[];
+    voterCount: number;
+}
+
+interface TelegramPoll {
+    id: string;
+    question: string;
+    questionEntities: TelegramMessageEntity[];
+    options: TelegramPollOption[];
+    totalVoterCount: number;
+    isClosed: boolean;
+    isAnonymous: boolean;
+    type: "regular" | "quiz";
+    allowsMultipleAnswers: boolean;
+    correctOptionId?: number;
+    explanation?: string;
+    explanationEntities: TelegramMessageEntity[];
+    openPeriod?: number;
+    closeDate?: number;
+}
+
+interface TelegramVenue {
+    location: TelegramLocation;
+    title: string;
+    address: string;
+    foursquareId?: string;
+    foursquareType?: string;
+    googlePlaceId?: string;
+    googlePlaceType?: string;
+}
+
+interface TelegramGame {
+    title: string;
+    description: string;
+    photo: TelegramPhotoSize[];
+    text?: string;
+    textEntities: TelegramMessageEntity[];
+    animation?: TelegramAnimation;
+}
+
+interface TelegramStory {
+    id: number;
+    chat: TelegramChat;
+}
+
+interface TelegramTextQuote {
+    text: string;
+    entities: TelegramMessageEntity[];
+    position: number;
+    isManual: boolean;
+}
+
+interface TelegramLinkPreviewOptions {
+    isDisabled?: boolean;
+    url?: string;
+    preferSmallMedia?: boolean;
+    preferLargeMedia?: boolean;
+    showAboveText?: boolean;
+}
+
+interface TelegramGiveaway {
+    chats: TelegramChat[];
+    winnersSelectionDate: number;
+    winnerCount: number;
+    onlyNewMembers: boolean;
+    hasPublicWinners: boolean;
+    prizeDescription?: string;
+    countryCodes?: string[];
+    prizeStarCount?: number;
+    premiumSubscriptionMonthCount?: number;
+}
+
+interface TelegramGiveawayWinners {
+    chat: TelegramChat;
+    giveawayMessageId: number;
+    winnersSelectionDate: number;
+    winnerCount: number;
+    winners: TelegramUser[];
+    additionalChatCount?: number;
+    premiumSubscriptionMonthCount?: number;
+    unclaimedPrizeCount?: number;
+    prizeStarCount?: number;
+    onlyNewMembers: boolean;
+    wasRefunded: boolean;
+    prizeDescription?: string;
+}
+
+interface TelegramExternalReply {
+    origin: TelegramForwardOrigin;
+    chat?: TelegramChat;
+    messageId?: number;
+    linkPreviewOptions?: TelegramLinkPreviewOptions;
+    animation?: TelegramAnimation;
+    audio?: TelegramAudio;
+    document?: TelegramDocument;
+    paidMedia?: TelegramPaidMediaInfo;
+    photo?: TelegramPhotoSize[];
+    sticker?: TelegramSticker;
+    story?: TelegramStory;
+    video?: TelegramVideo;
+    videoNote?: TelegramVideoNote;
+    voice?: TelegramVoice;
+    hasMediaSpoiler?: boolean;
+    contact?: TelegramContact;
+    dice?: TelegramDice;
+    game?: TelegramGame;
+    giveaway?: TelegramGiveaway;
+    giveawayWinners?: TelegramGiveawayWinners;
+    invoice?: TelegramInvoice;
+    location?: TelegramLocation;
+    poll?: TelegramPoll;
+    venue?: TelegramVenue;
+}
+
+interface TelegramSharedUser {
+    userId: number;
+    firstName?: string;
+    lastName?: string;
+    username?: string;
+    photo: TelegramPhotoSize[];
+}
+
+interface TelegramBackgroundFill {
+    type: "solid" | "gradient" | "freeform_gradient";
+    /** For type="solid", RGB24 */
+    color?: number;
+    /** For type="gradient" */
+    topColor?: number;
+    bottomColor?: number;
+    rotationAngle?: number;
+    /** For type="freeform_gradient" */
+    colors?: number[];
+}
+
+interface TelegramChatBackground {
+    type: "fill" | "wallpaper" | "pattern" | "chat_theme";
+    fill?: TelegramBackgroundFill;
+    document?: TelegramDocument;
+    darkThemeDimming?: number;
+    isBlurred?: boolean;
+    isMoving?: boolean;
+    intensity?: number;
+    isInverted?: boolean;
+    /** For type="chat_theme" */
+    themeName?: string;
+}
+
+interface TelegramPassportFile {
+    fileId: string;
+    fileUniqueId: string;
+    fileSize: number;
+    fileDate: number;
+}
+
+interface TelegramPassportData {
+    data: {
+        type: string;
+        data?: string;
+        phoneNumber?: string;
+        email?: string;
+        files: TelegramPassportFile[];
+        frontSide?: TelegramPassportFile;
+        reverseSide?: TelegramPassportFile;
+        selfie?: TelegramPassportFile;
+        translation: TelegramPassportFile[];
+        hash: string;
+    }[];
+    credentials: { data: string; hash: string; secret: string };
 }
 
 interface TelegramSticker {
@@ -74,6 +356,8 @@ interface TelegramSticker {
     type: string;
     emoji?: string;
     setName?: string;
+    customEmojiId?: string;
+    fileSize?: number;
     thumbnail?: TelegramPhotoSize;
 }
 
@@ -92,15 +376,171 @@ interface TelegramForwardOrigin {
     messageId?: number;
 }
 
+interface TelegramShippingAddress {
+    countryCode: string;
+    state: string;
+    city: string;
+    streetLine1: string;
+    streetLine2: string;
+    postCode: string;
+}
+
+interface TelegramOrderInfo {
+    name?: string;
+    phoneNumber?: string;
+    email?: string;
+    shippingAddress?: TelegramShippingAddress;
+}
+
+interface TelegramInvoice {
+    title: string;
+    description: string;
+    startParameter: string;
+    currency: string;
+    totalAmount: number;
+}
+
+interface TelegramSuccessfulPayment {
+    /** Three-letter ISO 4217 code, or "XTR" for Telegram Stars */
+    currency: string;
+    /** Amount in the smallest units of the currency */
+    totalAmount: number;
+    invoicePayload: string;
+    subscriptionExpirationDate?: number;
+    isRecurring?: boolean;
+    isFirstRecurring?: boolean;
+    shippingOptionId?: string;
+    orderInfo?: TelegramOrderInfo;
+    /** Pass to refundStarPayment to refund a Stars payment */
+    telegramPaymentChargeId: string;
+    providerPaymentChargeId: string;
+}
+
+interface TelegramRefundedPayment {
+    currency: string;
+    totalAmount: number;
+    invoicePayload: string;
+    telegramPaymentChargeId: string;
+    providerPaymentChargeId?: string;
+}
+
+interface TelegramShippingQuery {
+    id: string;
+    from: TelegramUser;
+    invoicePayload: string;
+    shippingAddress: TelegramShippingAddress;
+}
+
+interface TelegramPreCheckoutQuery {
+    id: string;
+    from: TelegramUser;
+    currency: string;
+    totalAmount: number;
+    invoicePayload: string;
+    shippingOptionId?: string;
+    orderInfo?: TelegramOrderInfo;
+}
+
 interface TelegramMessage {
     messageId: number;
+    /** Unix time; 0 for a message the bot can no longer access */
     date: number;
-    text?: string;
     chat: TelegramChat;
+    messageThreadId?: number;
     from?: TelegramUser;
-    photo?: TelegramPhotoSize[];
-    document?: TelegramDocument;
+    senderChat?: TelegramChat;
+    senderBoostCount?: number;
+    senderBusinessBot?: TelegramUser;
+    businessConnectionId?: string;
     forwardOrigin?: TelegramForwardOrigin;
+    isTopicMessage?: boolean;
+    isAutomaticForward?: boolean;
+    replyToMessage?: TelegramMessage;
+    /** The replied message when it is in another chat or forum topic */
+    externalReply?: TelegramExternalReply;
+    /** Quoted part of the replied message */
+    quote?: TelegramTextQuote;
+    replyToStory?: TelegramStory;
+    viaBot?: TelegramUser;
+    editDate?: number;
+    hasProtectedContent?: boolean;
+    isFromOffline?: boolean;
+    /** Shared by all messages of an album */
+    mediaGroupId?: string;
+    authorSignature?: string;
+    text?: string;
+    entities?: TelegramMessageEntity[];
+    linkPreviewOptions?: TelegramLinkPreviewOptions;
+    effectId?: string;
+    animation?: TelegramAnimation;
+    audio?: TelegramAudio;
+    document?: TelegramDocument;
+    paidMedia?: TelegramPaidMediaInfo;
+    /** Available sizes of the photo, smallest first */
+    photo?: TelegramPhotoSize[];
+    sticker?: TelegramSticker;
+    story?: TelegramStory;
+    video?: TelegramVideo;
+    videoNote?: TelegramVideoNote;
+    voice?: TelegramVoice;
+    caption?: string;
+    captionEntities?: TelegramMessageEntity[];
+    showCaptionAboveMedia?: boolean;
+    hasMediaSpoiler?: boolean;
+    contact?: TelegramContact;
+    dice?: TelegramDice;
+    game?: TelegramGame;
+    poll?: TelegramPoll;
+    venue?: TelegramVenue;
+    location?: TelegramLocation;
+    newChatMembers?: TelegramUser[];
+    leftChatMember?: TelegramUser;
+    newChatTitle?: string;
+    newChatPhoto?: TelegramPhotoSize[];
+    deleteChatPhoto?: boolean;
+    groupChatCreated?: boolean;
+    supergroupChatCreated?: boolean;
+    channelChatCreated?: boolean;
+    messageAutoDeleteTimerChanged?: { messageAutoDeleteTime: number };
+    migrateToChatId?: number;
+    migrateFromChatId?: number;
+    pinnedMessage?: TelegramMessage;
+    invoice?: TelegramInvoice;
+    successfulPayment?: TelegramSuccessfulPayment;
+    refundedPayment?: TelegramRefundedPayment;
+    usersShared?: { requestId: number; users: TelegramSharedUser[] };
+    chatShared?: { requestId: number; chatId: number; title?: string; username?: string; photo: TelegramPhotoSize[] };
+    connectedWebsite?: string;
+    writeAccessAllowed?: { fromRequest: boolean; webAppName?: string; fromAttachmentMenu: boolean };
+    passportData?: TelegramPassportData;
+    proximityAlertTriggered?: { traveler: TelegramUser; watcher: TelegramUser; distance: number };
+    boostAdded?: { boostCount: number };
+    chatBackgroundSet?: TelegramChatBackground;
+    forumTopicCreated?: { name: string; iconColor: number; iconCustomEmojiId?: string };
+    forumTopicEdited?: { name?: string; iconCustomEmojiId?: string };
+    forumTopicClosed?: boolean;
+    forumTopicReopened?: boolean;
+    generalForumTopicHidden?: boolean;
+    generalForumTopicUnhidden?: boolean;
+    giveawayCreated?: { prizeStarCount?: number };
+    giveaway?: TelegramGiveaway;
+    giveawayWinners?: TelegramGiveawayWinners;
+    giveawayCompleted?: { winnerCount: number; unclaimedPrizeCount?: number; isStarGiveaway: boolean; giveawayMessage?: TelegramMessage };
+    videoChatScheduled?: { startDate: number };
+    videoChatStarted?: boolean;
+    videoChatEnded?: { duration: number };
+    videoChatParticipantsInvited?: { users: TelegramUser[] };
+    /** Data sent from a Web App */
+    webAppData?: { data: string; buttonText: string };
+    /** Inline keyboard attached to the message */
+    replyMarkup?: { inlineKeyboard: (InlineKeyboardButton & Record<string, any>)[][] };
+}
+
+interface TelegramContextMessage extends TelegramMessage {
+    /** Text or caption with its entities rendered as Telegram HTML */
+    html(): string;
+    /** Text or caption with its entities rendered as Telegram MarkdownV2 */
+    markdownV2(): string;
 }
 
 interface TelegramCallbackQuery {
@@ -111,10 +551,223 @@ interface TelegramCallbackQuery {
     message?: TelegramMessage;
 }
 
+interface TelegramLocation {
+    latitude: number;
+    longitude: number;
+    horizontalAccuracy?: number;
+    livePeriod?: number;
+    heading?: number;
+}
+
+interface TelegramInlineQuery {
+    id: string;
+    from: TelegramUser;
+    query: string;
+    offset: string;
+    chatType?: string;
+    location?: TelegramLocation;
+}
+
+interface TelegramChosenInlineResult {
+    resultId: string;
+    from: TelegramUser;
+    query: string;
+    inlineMessageId?: string;
+    location?: TelegramLocation;
+}
+
+interface TelegramChatMember {
+    status: "creator" | "administrator" | "member" | "restricted" | "left" | "kicked";
+    user?: TelegramUser;
+    /** For "creator" and "administrator" */
+    isAnonymous?: boolean;
+    customTitle?: string;
+    /** Administrator rights */
+    canBeEdited?: boolean;
+    canManageChat?: boolean;
+    canDeleteMessages?: boolean;
+    canManageVideoChats?: boolean;
+    canRestrictMembers?: boolean;
+    canPromoteMembers?: boolean;
+    canPostMessages?: boolean;
+    canEditMessages?: boolean;
+    canPostStories?: boolean;
+    canEditStories?: boolean;
+    canDeleteStories?: boolean;
+    /** For "restricted" */
+    isMember?: boolean;
+    canSendMessages?: boolean;
+    canSendAudios?: boolean;
+    canSendDocuments?: boolean;
+    canSendPhotos?: boolean;
+    canSendVideos?: boolean;
+    canSendVideoNotes?: boolean;
+    canSendVoiceNotes?: boolean;
+    canSendPolls?: boolean;
+    canSendOtherMessages?: boolean;
+    canAddWebPagePreviews?: boolean;
+    /** Shared by administrators and restricted members */
+    canChangeInfo?: boolean;
+    canInviteUsers?: boolean;
+    canPinMessages?: boolean;
+    canManageTopics?: boolean;
+    /** Unix time the restriction, ban or subscription ends; 0 means forever */
+    untilDate?: number;
+}
+
+/** Permissions for restrictChatMember and setChatPermissions; omitted ones are denied */
+interface TelegramChatPermissions {
+    canSendMessages?: boolean;
+    canSendAudios?: boolean;
+    canSendDocuments?: boolean;
+    canSendPhotos?: boolean;
+    canSendVideos?: boolean;
+    canSendVideoNotes?: boolean;
+    canSendVoiceNotes?: boolean;
+    canSendPolls?: boolean;
+    canSendOtherMessages?: boolean;
+    canAddWebPagePreviews?: boolean;
+    canChangeInfo?: boolean;
+    canInviteUsers?: boolean;
+    canPinMessages?: boolean;
+    canManageTopics?: boolean;
+}
+
+/** Rights for promoteChatMember; omitted ones are not granted */
+interface TelegramAdministratorRights {
+    isAnonymous?: boolean;
+    canManageChat?: boolean;
+    canDeleteMessages?: boolean;
+    canManageVideoChats?: boolean;
+    canRestrictMembers?: boolean;
+    canPromoteMembers?: boolean;
+    canChangeInfo?: boolean;
+    canInviteUsers?: boolean;
+    canPostMessages?: boolean;
+    canEditMessages?: boolean;
+    canPinMessages?: boolean;
+    canPostStories?: boolean;
+    canEditStories?: boolean;
+    canDeleteStories?: boolean;
+    canManageTopics?: boolean;
+}
+
+interface BanChatMemberOptions {
+    /** Unix time the ban ends; 0 or less than 30 seconds away bans forever */
+    untilDate?: number;
+    /** Delete all messages of the user in the chat */
+    revokeMessages?: boolean;
+}
+
+interface RestrictChatMemberOptions {
+    /** Unix time the restriction ends; 0 restricts forever */
+    untilDate?: number;
+    useIndependentChatPermissions?: boolean;
+}
+
+interface TelegramChatInviteLink {
+    inviteLink: string;
+    creator: TelegramUser;
+    createsJoinRequest: boolean;
+    isPrimary: boolean;
+    isRevoked: boolean;
+    name?: string;
+    expireDate?: number;
+    memberLimit?: number;
+    pendingJoinRequestCount?: number;
+}
+
+interface TelegramChatMemberUpdated {
+    chat: TelegramChat;
+    /** Who made the change */
+    from: TelegramUser;
+    date: number;
+    oldChatMember: TelegramChatMember;
+    newChatMember: TelegramChatMember;
+    inviteLink?: TelegramChatInviteLink;
+    viaJoinRequest: boolean;
+    viaChatFolderInviteLink: boolean;
+}
+
+interface TelegramChatJoinRequest {
+    chat: TelegramChat;
+    from: TelegramUser;
+    /** Private chat with the user, usable for 5 minutes after the request */
+    userChatId: number;
+    date: number;
+    bio?: string;
+    inviteLink?: TelegramChatInviteLink;
+}
+
+type TelegramUpdateType =
+    | "message"
+    | "edited_message"
+    | "channel_post"
+    | "edited_channel_post"
+    | "business_connection"
+    | "business_message"
+    | "edited_business_message"
+    | "deleted_business_messages"
+    | "message_reaction"
+    | "message_reaction_count"
+    | "inline_query"
+    | "chosen_inline_result"
+    | "callback_query"
+    | "shipping_query"
+    | "pre_checkout_query"
+    | "purchased_paid_media"
+    | "poll"
+    | "poll_answer"
+    | "my_chat_member"
+    | "chat_member"
+    | "chat_join_request"
+    | "chat_boost"
+    | "removed_chat_boost";
+
+interface TelegramReactionType {
+    type: "emoji" | "custom_emoji" | "paid";
+    emoji?: string;
+    customEmojiId?: string;
+}
+
+interface TelegramChatBoostSource {
+    source: "premium" | "gift_code" | "giveaway";
+    user?: TelegramUser;
+    /** For source="giveaway" */
+    giveawayMessageId?: number;
+    prizeStarCount?: number;
+    isUnclaimed?: boolean;
+}
+
 interface TelegramUpdate {
     updateId: number;
     message?: TelegramMessage;
+    editedMessage?: TelegramMessage;
+    channelPost?: TelegramMessage;
+    editedChannelPost?: TelegramMessage;
     callbackQuery?: TelegramCallbackQuery;
+    inlineQuery?: TelegramInlineQuery;
+    chosenInlineResult?: TelegramChosenInlineResult;
+    shippingQuery?: TelegramShippingQuery;
+    preCheckoutQuery?: TelegramPreCheckoutQuery;
+    /** The bot's own membership changed */
+    myChatMember?: TelegramChatMemberUpdated;
+    /** Another member's status changed; requires "chat_member" in allowedUpdates */
+    chatMember?: TelegramChatMemberUpdated;
+    chatJoinRequest?: TelegramChatJoinRequest;
+    businessConnection?: { id: string; user: TelegramUser; userChatId: number; date: number; canReply: boolean; isEnabled: boolean };
+    businessMessage?: TelegramMessage;
+    editedBusinessMessage?: TelegramMessage;
+    deletedBusinessMessages?: { businessConnectionId: string; chat: TelegramChat; messageIds: number[] };
+    /** Requires "message_reaction" in allowedUpdates and admin rights */
+    messageReaction?: { chat: TelegramChat; messageId: number; user?: TelegramUser; actorChat?: TelegramChat; date: number; oldReaction: TelegramReactionType[]; newReaction: TelegramReactionType[] };
+    /** Requires "message_reaction_count" in allowedUpdates and admin rights */
+    messageReactionCount?: { chat: TelegramChat; messageId: number; date: number; reactions: { type: TelegramReactionType; totalCount: number }[] };
+    purchasedPaidMedia?: { from: TelegramUser; paidMediaPayload: string };
+    poll?: TelegramPoll;
+    pollAnswer?: { pollId: string; voterChat?: TelegramChat; user?: TelegramUser; optionIds: number[] };
+    chatBoost?: { chat: TelegramChat; boost: { boostId: string; addDate: number; expirationDate: number; source: TelegramChatBoostSource } };
+    removedChatBoost?: { chat: TelegramChat; boostId: string; removeDate: number; source: TelegramChatBoostSource };
 }
 
 interface InlineKeyboardButton {
@@ -137,6 +790,8 @@ interface SendMessageOptions {
     resizeKeyboard?: boolean;
     oneTimeKeyboard?: boolean;
     parseMode?: "HTML" | "Markdown" | "MarkdownV2";
+    /** Formatting of the plain text instead of parseMode, e.g. message.entities of a received message */
+    entities?: TelegramMessageEntity[];
     disableWebPagePreview?: boolean;
 }
 
@@ -155,10 +810,304 @@ interface EditMessageOptions {
     inlineKeyboard?: InlineKeyboardButton[][];
 }
 
+interface StartBotOptions {
+    /** How updates are received (default "polling") */
+    mode?: "polling" | "webhook";
+    /** Public HTTPS URL passed to setWebhook; when omitted setWebhook is not called */
+    url?: string;
+    /** Value expected in the X-Telegram-Bot-Api-Secret-Token header */
+    secretToken?: string;
+    /** Local address for the webhook server (default ":8443") */
+    listen?: string;
+    /** HTTP path served by the webhook server (default "/") */
+    path?: string;
+    /** Bot API server URL (default "https://api.telegram.org") */
+    apiUrl?: string;
+    /** The server at apiUrl is a local Bot API server on this machine: files are passed as file:// paths instead of uploads */
+    localMode?: boolean;
+    /** Proxy URL for this bot (http://, https:// or socks5://), overriding the plugin config */
+    proxy?: string;
+    /** Bot API request timeout in seconds (default from plugin config, 30) */
+    requestTimeout?: number;
+    /** Long polling timeout in seconds (default from plugin config, 25) */
+    pollTimeout?: number;
+    /** Number of recent update IDs remembered to skip redelivered updates (default 0) */
+    dedupWindow?: number;
+    /** Outgoing message rate limits; false disables pacing (retries stay enabled) */
+    rateLimit?: boolean | RateLimitOptions;
+    /** Enable ctx.session; true uses the defaults */
+    session?: boolean | SessionOptions;
+    /** Update types Telegram should send (default: the types with a registered handler) */
+    allowedUpdates?: TelegramUpdateType[];
+}
+
+interface RateLimitOptions {
+    /** Messages per second across all chats (default 30) */
+    global?: number;
+    /** Messages per second in one chat (default 1, short bursts of 3 allowed) */
+    perChat?: number;
+    /** Messages per minute in one group or channel (default 20) */
+    perGroup?: number;
+}
+
+interface BroadcastMessage {
+    text?: string;
+    /** file_id or URL */
+    photo?: string;
+    video?: string;
+    document?: string;
+    animation?: string;
+    caption?: string;
+    /** Default "HTML" */
+    parseMode?: string;
+    inlineKeyboard?: InlineKeyboardButton[][];
+    disableNotification?: boolean;
+    disableWebPagePreview?: boolean;
+    /** Copy an existing message instead */
+    copy?: { chatId: number | string; messageId: number };
+}
+
+interface BroadcastOptions {
+    /** Stable job ID; broadcasting again with the ID of an unfinished job resumes it */
+    id?: string;
+    /** Parallel senders (default 4, max 30) */
+    concurrency?: number;
+    /** Called about once a second and when the job ends */
+    onProgress?: (progress: BroadcastProgress) => void;
+}
+
+interface BroadcastProgress {
+    id: string;
+    status: "running" | "done" | "cancelled";
+    total: number;
+    pending: number;
+    sent: number;
+    blocked: number;
+    notFound: number;
+    deactivated: number;
+    failed: number;
+}
+
+interface BroadcastJob {
+    id: string;
+    status(): BroadcastProgress;
+    /** Stop sending; the job is not resumed */
+    cancel(): void;
+    /** Outcome per recipient; an empty status means pending */
+    results(): { chatId: number | string; status: "" | "sent" | "blocked" | "not_found" | "deactivated" | "failed" }[];
+}
+
+/** Stable category of a Bot API error */
+type TelegramErrorKind =
+    | "blocked"
+    | "user_deactivated"
+    | "kicked"
+    | "chat_not_found"
+    | "message_not_modified"
+    | "message_not_found"
+    | "query_expired"
+    | "not_enough_rights"
+    | "chat_migrated"
+    | "too_many_requests"
+    | "bad_request"
+    | "unauthorized"
+    | "forbidden"
+    | "not_found"
+    | "conflict"
+    | "server_error"
+    | "network"
+    | "unknown";
+
+/** Thrown by every method that calls the Bot API */
+interface TelegramError extends Error {
+    name: "TelegramError";
+    /** HTTP-like error code from Telegram (0 for network errors) */
+    code: number;
+    /** Error description from Telegram */
+    description: string;
+    kind: TelegramErrorKind;
+    /** Seconds to wait before retrying (too_many_requests) */
+    retryAfter?: number;
+    /** New supergroup ID (chat_migrated) */
+    migrateToChatId?: number;
+}
+
+interface TelegramFile {
+    fileId: string;
+    fileUniqueId: string;
+    fileSize: number;
+    /** Path to download the file from; absolute on a local Bot API server */
+    filePath: string;
+    /** Absolute path of the file on disk (localMode only) */
+    localPath?: string;
+}
+
+interface DownloadOptions {
+    /** Reject files larger than this many bytes */
+    maxSize?: number;
+    /** Give up after this many seconds; defaults to the request timeout plus the file size at 256 KB/s, at most 10 in handlers */
+    timeout?: number;
+}
+
+interface DownloadedFile {
+    /** Absolute path of the saved file */
+    path: string;
+    size: number;
+    mimeType: string;
+    fileId: string;
+    fileUniqueId: string;
+}
+
+interface SessionOptions {
+    /** Where sessions are kept (default "memory"); "file" requires storage_path */
+    store?: "memory" | "file" | string;
+    /** What a session belongs to (default "chat") */
+    key?: "chat" | "user" | "chat_user";
+    /** Seconds of inactivity after which a session is dropped (default 0, never) */
+    ttl?: number;
+}
+
+interface InlineResultOptions {
+    title?: string;
+    description?: string;
+    caption?: string;
+    parseMode?: "HTML" | "Markdown" | "MarkdownV2";
+    thumbnailUrl?: string;
+    /** Message text sent instead of the media (required for articles) */
+    text?: string;
+    disableWebPagePreview?: boolean;
+    inlineKeyboard?: InlineKeyboardButton[][];
+    width?: number;
+    height?: number;
+    duration?: number;
+    mimeType?: string;
+    url?: string;
+}
+
+interface InlineQueryResult extends InlineResultOptions {
+    type: "article" | "photo" | "gif" | "video" | "document" | "cached";
+    id: string;
+    photoUrl?: string;
+    gifUrl?: string;
+    videoUrl?: string;
+    documentUrl?: string;
+    /** For type="cached" */
+    fileType?: "photo" | "gif" | "mpeg4_gif" | "sticker" | "document" | "video" | "voice" | "audio";
+    fileId?: string;
+}
+
+interface AnswerInlineQueryOptions {
+    cacheTime?: number;
+    isPersonal?: boolean;
+    nextOffset?: string;
+    /** Button shown above the results */
+    button?: { text: string; startParameter?: string; webAppUrl?: string };
+}
+
+interface TelegramInlineResultBuilders {
+    article(id: string, title: string, text: string, options?: InlineResultOptions): InlineQueryResult;
+    photo(id: string, photoUrl: string, options?: InlineResultOptions): InlineQueryResult;
+    gif(id: string, gifUrl: string, options?: InlineResultOptions): InlineQueryResult;
+    video(id: string, videoUrl: string, title: string, options?: InlineResultOptions): InlineQueryResult;
+    document(id: string, documentUrl: string, title: string, options?: InlineResultOptions): InlineQueryResult;
+    /** Result referencing a file already uploaded to Telegram */
+    cached(fileType: "photo" | "gif" | "mpeg4_gif" | "sticker" | "document" | "video" | "voice" | "audio", id: string, fileId: string, options?: InlineResultOptions): InlineQueryResult;
+}
+
+interface LabeledPrice {
+    label: string;
+    /** Amount in the smallest units of the currency (cents, or Stars for "XTR") */
+    amount: number;
+}
+
+interface InvoiceParams {
+    title: string;
+    description: string;
+    /** Bot-defined payload, not shown to the user; used to route shipping and pre-checkout queries */
+    payload: string;
+    /** Payment provider token; ignored for Telegram Stars */
+    providerToken?: string;
+    /** Three-letter ISO 4217 code, or "XTR" for Telegram Stars */
+    currency: string;
+    prices: LabeledPrice[];
+    maxTipAmount?: number;
+    suggestedTipAmounts?: number[];
+    startParameter?: string;
+    providerData?: string;
+    photoUrl?: string;
+    photoWidth?: number;
+    photoHeight?: number;
+    needName?: boolean;
+    needPhoneNumber?: boolean;
+    needEmail?: boolean;
+    needShippingAddress?: boolean;
+    sendPhoneNumberToProvider?: boolean;
+    sendEmailToProvider?: boolean;
+    isFlexible?: boolean;
+    /** sendInvoice only */
+    inlineKeyboard?: InlineKeyboardButton[][];
+    /** createInvoiceLink only: subscription period in seconds (Stars) */
+    subscriptionPeriod?: number;
+}
+
+interface AnswerShippingQueryOptions {
+    /** Required when ok is true */
+    shippingOptions?: { id: string; title: string; prices: LabeledPrice[] }[];
+    /** Required when ok is false */
+    errorMessage?: string;
+}
+
+interface SceneOptions {
+    /** Seconds of inactivity after which the chat leaves the scene (default 600, 0 disables) */
+    timeout?: number;
+    /** Command that leaves the scene (default "/cancel", "" disables) */
+    cancelCommand?: string;
+    /** Called when the cancel command is received */
+    onCancel?: (ctx: TelegramContext) => void;
+}
+
+interface TelegramWizard {
+    /** Name of the current scene */
+    scene?: string;
+    /** Index of the current step */
+    step?: number;
+    /** Scene data persisted between steps; must be JSON-serializable */
+    state?: Record<string, any>;
+    /** Handle the next update with the next step; leaves the scene after the last step */
+    next(): void;
+    /** Handle the next update with the previous step */
+    back(): void;
+    /** Handle the next update with the given step */
+    selectStep(step: number): void;
+}
+
+interface TelegramRoute {
+    /** Which updates the route applies to */
+    source: "message" | "callback" | "inline_query" | "shipping_query" | "pre_checkout_query" | "any";
+    kind: "exact" | "command" | "prefix" | "params" | "regex" | "default";
+    pattern?: string;
+}
+
 interface TelegramContext {
     /** The raw update object */
     update: TelegramUpdate;
-    /** Reply with a text message */
+    /** Per-update state shared between middlewares and the handler */
+    state: Record<string, any>;
+    /** The message of a message, edited message, channel post or business message update */
+    message?: TelegramContextMessage;
+    /** Persistent session, saved after the handler succeeds; set to null to clear (requires the session option) */
+    session?: Record<string, any> | null;
+    /** Regex or route match result: full match followed by captured groups */
+    match?: string[];
+    /** Named groups of a RegExp or {param} placeholders of a route-style pattern */
+    params?: Record<string, string>;
+    /** Command name without the slash and @botname, e.g. "ban" for "/ban@mybot 12345 spam" */
+    command?: string;
+    /** Command arguments split shell-style, quotes group words: ["12345", "spam"] */
+    args?: string[];
+    /** Raw text after the command, e.g. "ref_42" for the deep link "/start ref_42" */
+    payload?: string;
+    /** Reply with a text message; business messages are answered through their business connection */
     reply(text: string): TelegramMessage;
     /** Reply with a photo */
     replyPhoto(photo: string, caption?: string): TelegramMessage;
@@ -168,19 +1117,55 @@ interface TelegramContext {
     replyWithInlineKeyboard(text: string, keyboard: InlineKeyboardButton[][]): TelegramMessage;
     /** Answer callback query (for inline buttons) */
     answerCallback(text?: string, showAlert?: boolean): void;
+    /** Answer the current inline query */
+    answerInlineQuery(results: InlineQueryResult[], options?: AnswerInlineQueryOptions): void;
+    /** Answer the current shipping query */
+    answerShippingQuery(ok: boolean, options?: AnswerShippingQueryOptions): void;
+    /** Answer the current pre-checkout query within 10 seconds */
+    answerPreCheckoutQuery(ok: boolean, errorMessage?: string): void;
+    /** Enter a scene for the current chat and run its first step */
+    enter(scene: string, state?: Record<string, any>): void;
+    /** Leave the current scene */
+    leave(): void;
+    /** Scene step navigation */
+    wizard: TelegramWizard;
     /** Edit the message (for callback queries) */
     editMessage(text: string, options?: EditMessageOptions): TelegramMessage;
     /** Delete the current message */
     deleteMessage(): void;
+    /** Download the largest size of the message photo into storage */
+    downloadPhoto(destPath?: string, options?: DownloadOptions): DownloadedFile;
+    /** Approve the join request of a chat_join_request update */
+    approveJoinRequest(): void;
+    /** Decline the join request of a chat_join_request update */
+    declineJoinRequest(): void;
 }
 
 interface TelegramBotInstance {
-    /** Register a handler for a command or text pattern */
-    handle(pattern: string, handler: (ctx: TelegramContext) => void): void;
-    /** Register a handler for callback query data */
-    handleCallback(data: string, handler: (ctx: TelegramContext) => void): void;
+    /** Register a handler for a command, exact text, route-style pattern or RegExp */
+    handle(pattern: string | RegExp, handler: (ctx: TelegramContext) => void): void;
+    /** Register a handler for message text; alias of handle */
+    hears(pattern: string | RegExp, handler: (ctx: TelegramContext) => void): void;
+    /** Register a handler for callback query data: exact, "prefix*", "product:{id}" or RegExp */
+    handleCallback(data: string | RegExp, handler: (ctx: TelegramContext) => void): void;
+    /** Register a handler for inline queries; use "*" to match every query */
+    handleInlineQuery(pattern: string | RegExp, handler: (ctx: TelegramContext) => void): void;
+    /** Register a handler for shipping queries, matched against the invoice payload */
+    handleShippingQuery(payload: string | RegExp, handler: (ctx: TelegramContext) => void): void;
+    /** Register a handler for pre-checkout queries, matched against the invoice payload */
+    handlePreCheckoutQuery(payload: string | RegExp, handler: (ctx: TelegramContext) => void): void;
+    /** Register a handler for an update type; for routed types it gets what no pattern matched */
+    on(type: TelegramUpdateType, handler: (ctx: TelegramContext) => void): void;
     /** Register a default handler for unmatched messages */
     handleDefault(handler: (ctx: TelegramContext) => void): void;
+    /** Register a scene: a sequence of steps that handle a chat's updates while it is inside the scene */
+    scene(name: string, steps: ((ctx: TelegramContext) => void)[], options?: SceneOptions): void;
+    /** List registered routes in resolution order (for debugging) */
+    routes(): TelegramRoute[];
+    /** Register a middleware that runs before every handler; call next() to continue the chain */
+    use(middleware: (ctx: TelegramContext, next: () => void) => void): void;
+    /** Handle exceptions thrown by handlers and middlewares, and Go panics (err.name === "PanicError") */
+    catch(handler: (err: any, ctx: TelegramContext) => void): void;
     /** Send a text message */
     sendMessage(chatId: number, text: string, options?: SendMessageOptions): TelegramMessage;
     /** Send a photo (file path, URL, file_id, or base64) */
@@ -203,10 +1188,60 @@ interface TelegramBotInstance {
     deleteMessage(chatId: number, messageId: number): void;
     /** Answer a callback query */
     answerCallback(callbackId: string, text?: string, showAlert?: boolean): void;
+    /** Answer an inline query by ID */
+    answerInlineQuery(inlineQueryId: string, results: InlineQueryResult[], options?: AnswerInlineQueryOptions): void;
+    /** Inline query result builders */
+    inline: TelegramInlineResultBuilders;
+    /** Send an invoice */
+    sendInvoice(chatId: number, invoice: InvoiceParams): TelegramMessage;
+    /** Create a payment link for an invoice */
+    createInvoiceLink(invoice: InvoiceParams): string;
+    /** Answer a shipping query by ID */
+    answerShippingQuery(shippingQueryId: string, ok: boolean, options?: AnswerShippingQueryOptions): void;
+    /** Answer a pre-checkout query by ID */
+    answerPreCheckoutQuery(preCheckoutQueryId: string, ok: boolean, errorMessage?: string): void;
+    /** Refund a successful Telegram Stars payment */
+    refundStarPayment(userId: number, telegramPaymentChargeId: string): void;
+    /** Send a message to many chats in the background within rate limits */
+    broadcast(chatIds: (number | string)[], message: BroadcastMessage, options?: BroadcastOptions): BroadcastJob;
+    /** Get a broadcast job by ID, including jobs restored after a restart */
+    getBroadcast(id: string): BroadcastJob | null;
     /** Get bot info */
     getMe(): TelegramUser;
+    /** Log out from the cloud Bot API server before moving the bot to a local server */
+    logOut(): void;
+    /** Close the bot instance before moving it from one local server to another */
+    close(): void;
+    /** Get file info for downloading */
+    getFile(fileId: string): TelegramFile;
+    /** Download a file into storage; destPath defaults to "downloads/<fileUniqueId><ext>" */
+    downloadFile(fileId: string, destPath?: string, options?: DownloadOptions): DownloadedFile;
     /** Get chat member info */
-    getChatMember(chatId: number, userId: number): { status: string; user?: TelegramUser };
+    getChatMember(chatId: number, userId: number): TelegramChatMember;
+    /** Let a user who sent a join request into the chat */
+    approveChatJoinRequest(chatId: number, userId: number): void;
+    /** Decline a join request */
+    declineChatJoinRequest(chatId: number, userId: number): void;
+    /** Ban a user; they cannot return through invite links until unbanned */
+    banChatMember(chatId: number, userId: number, options?: BanChatMemberOptions): void;
+    /** Unban a user; without onlyIfBanned a current member is removed too */
+    unbanChatMember(chatId: number, userId: number, options?: { onlyIfBanned?: boolean }): void;
+    /** Restrict a member of a supergroup to the given permissions */
+    restrictChatMember(chatId: number, userId: number, permissions: TelegramChatPermissions, options?: RestrictChatMemberOptions): void;
+    /** Promote a member; passing no rights demotes them */
+    promoteChatMember(chatId: number, userId: number, rights: TelegramAdministratorRights): void;
+    /** Set the custom title of an administrator promoted by the bot */
+    setChatAdministratorCustomTitle(chatId: number, userId: number, title: string): void;
+    /** Stop a channel from posting in the chat on its behalf */
+    banChatSenderChat(chatId: number, senderChatId: number): void;
+    unbanChatSenderChat(chatId: number, senderChatId: number): void;
+    /** Set the default permissions of all members */
+    setChatPermissions(chatId: number, permissions: TelegramChatPermissions, options?: { useIndependentChatPermissions?: boolean }): void;
+    /** Get the administrators of a chat, excluding other bots */
+    getChatAdministrators(chatId: number): TelegramChatMember[];
+    getChatMemberCount(chatId: number): number;
+    /** Leave a group, supergroup or channel */
+    leaveChat(chatId: number): void;
 }`,
 	}
 }
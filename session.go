@@ -0,0 +1,327 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cast"
+)
+
+const (
+	sessionKeyChat     = "chat"
+	sessionKeyUser     = "user"
+	sessionKeyChatUser = "chat_user"
+	sessionsDir        = "sessions"
+	sessionSweepPeriod = time.Minute
+)
+
+// SessionStore persists ctx.session between updates. Implementations must
+// be safe for concurrent use.
+type SessionStore interface {
+	// Get returns the session stored under key, or nil if there is none or
+	// it has expired
+	Get(key string) (map[string]interface{}, error)
+	// Set stores the session under key. A zero ttl never expires.
+	Set(key string, data map[string]interface{}, ttl time.Duration) error
+	// Delete removes the session stored under key
+	Delete(key string) error
+}
+
+// SessionStoreFactory creates a session store for the bot with the given ID
+type SessionStoreFactory func(botID string) (SessionStore, error)
+
+// RegisterSessionStore makes a custom store available to startBot under
+// name, e.g. {session: {store: "redis"}}
+func (p *TelegramPlugin) RegisterSessionStore(name string, factory SessionStoreFactory) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.sessionStores == nil {
+		p.sessionStores = make(map[string]SessionStoreFactory)
+	}
+	p.sessionStores[name] = factory
+}
+
+// sessionConfig holds the session options passed to startBot
+type sessionConfig struct {
+	store string
+	key   string
+	ttl   time.Duration
+}
+
+// parseSessionOptions converts the startBot "session" option. Sessions are
+// disabled when the option is missing or false.
+func parseSessionOptions(value interface{}) (*sessionConfig, error) {
+	cfg := &sessionConfig{
+		store: "memory",
+		key:   sessionKeyChat,
+	}
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case bool:
+		if !v {
+			return nil, nil
+		}
+	case map[string]interface{}:
+		if store := cast.ToString(v["store"]); store != "" {
+			cfg.store = store
+		}
+		if key := cast.ToString(v["key"]); key != "" {
+			cfg.key = key
+		}
+		cfg.ttl = time.Duration(cast.ToInt64(v["ttl"])) * time.Second
+	default:
+		return nil, fmt.Errorf("session option must be a boolean or an object")
+	}
+
+	switch cfg.key {
+	case sessionKeyChat, sessionKeyUser, sessionKeyChatUser:
+	default:
+		return nil, fmt.Errorf("unknown session key %q, expected \"chat\", \"user\" or \"chat_user\"", cfg.key)
+	}
+	return cfg, nil
+}
+
+// newSessionStore creates the store selected in cfg for this bot. Must be
+// called with p.mu held.
+func (instance *BotInstance) newSessionStore(cfg *sessionConfig) (SessionStore, error) {
+	switch cfg.store {
+	case "memory":
+		return newMemorySessionStore(), nil
+	case "file":
+		dir := instance.dataPath(sessionsDir)
+		if dir == "" {
+			return nil, fmt.Errorf("file session store requires storage_path")
+		}
+		return newFileSessionStore(dir), nil
+	}
+	if factory, ok := instance.plugin.sessionStores[cfg.store]; ok {
+		return factory(instance.id)
+	}
+	return nil, fmt.Errorf("unknown session store %q", cfg.store)
+}
+
+// sessionKey returns the store key of the current update, or "" when the
+// update has no chat or user to attach a session to
+func (uctx *UpdateContext) sessionKey() string {
+	chatID := uctx.getChatID()
+	userID := uctx.getUserID()
+
+	switch uctx.instance.sessionConfig.key {
+	case sessionKeyUser:
+		if userID != 0 {
+			return fmt.Sprintf("%d", userID)
+		}
+	case sessionKeyChatUser:
+		if chatID != 0 && userID != 0 {
+			return fmt.Sprintf("%d:%d", chatID, userID)
+		}
+	default:
+		if chatID != 0 {
+			return fmt.Sprintf("%d", chatID)
+		}
+	}
+	return ""
+}
+
+// loadSession fetches the session of the current update into uctx. Updates
+// without a session key get a fresh session that is never saved.
+func (instance *BotInstance) loadSession(uctx *UpdateContext) {
+	uctx.sessionID = uctx.sessionKey()
+	uctx.session = nil
+	if uctx.sessionID != "" {
+		data, err := instance.sessions.Get(uctx.sessionID)
+		if err != nil {
+			fmt.Printf("[ERROR] Failed to load session: %v\n", err)
+		}
+		uctx.session = data
+	}
+	if uctx.session == nil {
+		uctx.session = make(map[string]interface{})
+	}
+	uctx.sessionSnapshot, _ = json.Marshal(uctx.session)
+}
+
+// saveSession writes ctx.session back to the store. Handlers may mutate the
+// session in place or replace it; assigning null clears it.
+func (instance *BotInstance) saveSession(uctx *UpdateContext, ctxObj map[string]interface{}) {
+	if uctx.sessionID == "" {
+		return
+	}
+
+	data, _ := ctxObj["session"].(map[string]interface{})
+	if len(data) == 0 {
+		if len(uctx.sessionSnapshot) > 2 { // was not "{}"
+			if err := instance.sessions.Delete(uctx.sessionID); err != nil {
+				fmt.Printf("[ERROR] Failed to delete session: %v\n", err)
+			}
+		}
+		return
+	}
+
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		fmt.Printf("[ERROR] Session is not serializable: %v\n", err)
+		return
+	}
+	// Unchanged sessions are only rewritten to extend their TTL
+	if bytes.Equal(encoded, uctx.sessionSnapshot) && instance.sessionConfig.ttl == 0 {
+		return
+	}
+	if err := instance.sessions.Set(uctx.sessionID, data, instance.sessionConfig.ttl); err != nil {
+		fmt.Printf("[ERROR] Failed to save session: %v\n", err)
+	}
+}
+
+// memorySessionStore keeps sessions in memory; they are lost on restart
+type memorySessionStore struct {
+	mu        sync.Mutex
+	entries   map[string]sessionEntry
+	lastSweep time.Time
+}
+
+// sessionEntry is a stored session with its expiry (unix seconds, 0 = never)
+type sessionEntry struct {
+	Data      json.RawMessage `json:"data"`
+	ExpiresAt int64           `json:"expiresAt,omitempty"`
+}
+
+func (e sessionEntry) expired(now time.Time) bool {
+	return e.ExpiresAt > 0 && now.Unix() > e.ExpiresAt
+}
+
+func newSessionEntry(data map[string]interface{}, ttl time.Duration) (sessionEntry, error) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return sessionEntry{}, fmt.Errorf("failed to encode session: %w", err)
+	}
+	entry := sessionEntry{Data: encoded}
+	if ttl > 0 {
+		entry.ExpiresAt = time.Now().Add(ttl).Unix()
+	}
+	return entry, nil
+}
+
+// decode returns a fresh copy of the stored session so handlers never share
+// maps between updates
+func (e sessionEntry) decode() (map[string]interface{}, error) {
+	var data map[string]interface{}
+	if err := json.Unmarshal(e.Data, &data); err != nil {
+		return nil, fmt.Errorf("failed to decode session: %w", err)
+	}
+	return data, nil
+}
+
+func newMemorySessionStore() *memorySessionStore {
+	return &memorySessionStore{
+		entries:   make(map[string]sessionEntry),
+		lastSweep: time.Now(),
+	}
+}
+
+func (s *memorySessionStore) Get(key string) (map[string]interface{}, error) {
+	s.mu.Lock()
+	entry, ok := s.entries[key]
+	if ok && entry.expired(time.Now()) {
+		delete(s.entries, key)
+		ok = false
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		return nil, nil
+	}
+	return entry.decode()
+}
+
+func (s *memorySessionStore) Set(key string, data map[string]interface{}, ttl time.Duration) error {
+	entry, err := newSessionEntry(data, ttl)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[key] = entry
+
+	// Drop expired sessions of chats that never came back
+	now := time.Now()
+	if now.Sub(s.lastSweep) >= sessionSweepPeriod {
+		for k, e := range s.entries {
+			if e.expired(now) {
+				delete(s.entries, k)
+			}
+		}
+		s.lastSweep = now
+	}
+	return nil
+}
+
+func (s *memorySessionStore) Delete(key string) error {
+	s.mu.Lock()
+	delete(s.entries, key)
+	s.mu.Unlock()
+	return nil
+}
+
+// fileSessionStore keeps one JSON file per session in a directory
+type fileSessionStore struct {
+	mu  sync.Mutex
+	dir string
+}
+
+func newFileSessionStore(dir string) *fileSessionStore {
+	return &fileSessionStore{dir: dir}
+}
+
+// path maps a session key to its file; keys only contain digits, "-" and ":"
+func (s *fileSessionStore) path(key string) string {
+	return filepath.Join(s.dir, strings.ReplaceAll(key, ":", "_")+".json")
+}
+
+func (s *fileSessionStore) Get(key string) (map[string]interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var entry sessionEntry
+	if err := readJSONFile(s.path(key), &entry); err != nil {
+		return nil, err
+	}
+	if entry.Data == nil {
+		return nil, nil
+	}
+	if entry.expired(time.Now()) {
+		os.Remove(s.path(key))
+		return nil, nil
+	}
+	return entry.decode()
+}
+
+func (s *fileSessionStore) Set(key string, data map[string]interface{}, ttl time.Duration) error {
+	entry, err := newSessionEntry(data, ttl)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return writeJSONFile(s.path(key), entry)
+}
+
+func (s *fileSessionStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := os.Remove(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/spf13/cast"
+)
+
+const (
+	defaultRequestTimeout = 30 * time.Second
+	defaultPollTimeout    = 25 * time.Second
+)
+
+// httpConfig holds the network settings from the plugin config. Bots can
+// override the proxy and timeouts in their startBot options.
+type httpConfig struct {
+	skipTLSVerify  bool
+	caFile         string
+	proxy          string
+	requestTimeout time.Duration
+	pollTimeout    time.Duration
+}
+
+// parseHTTPConfig reads the network settings from the plugin config
+func parseHTTPConfig(config map[string]interface{}) httpConfig {
+	cfg := httpConfig{
+		requestTimeout: defaultRequestTimeout,
+		pollTimeout:    defaultPollTimeout,
+	}
+	if skipTLS, ok := config["skipTLSVerify"].(bool); ok {
+		cfg.skipTLSVerify = skipTLS
+	}
+	cfg.caFile = cast.ToString(config["caFile"])
+	cfg.proxy = cast.ToString(config["proxy"])
+	if timeout := cast.ToInt(config["requestTimeout"]); timeout > 0 {
+		cfg.requestTimeout = time.Duration(timeout) * time.Second
+	}
+	if timeout := cast.ToInt(config["pollTimeout"]); timeout > 0 {
+		cfg.pollTimeout = time.Duration(timeout) * time.Second
+	}
+	return cfg
+}
+
+// newTransport builds a transport that verifies certificates against the
+// system roots plus the optional CA bundle, and dials through proxy when set.
+// Proxy URLs may use the http, https or socks5 scheme.
+func newTransport(cfg httpConfig, proxy string) (*http.Transport, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.skipTLSVerify {
+		tlsConfig.InsecureSkipVerify = true
+	}
+	if cfg.caFile != "" {
+		pem, err := os.ReadFile(cfg.caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", cfg.caFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   10 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSClientConfig:     tlsConfig,
+		TLSHandshakeTimeout: 10 * time.Second,
+		ForceAttemptHTTP2:   true,
+		MaxIdleConns:        100,
+		MaxIdleConnsPerHost: 32,
+		IdleConnTimeout:     90 * time.Second,
+	}
+	if proxy != "" {
+		proxyURL, err := url.Parse(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL: %w", err)
+		}
+		switch proxyURL.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return nil, fmt.Errorf("unsupported proxy scheme %q, expected http, https or socks5", proxyURL.Scheme)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	return transport, nil
+}
+
+// transportFor returns the shared transport for proxy, so bots using the same
+// proxy share one connection pool. Must be called with p.mu held.
+func (p *TelegramPlugin) transportFor(proxy string) (*http.Transport, error) {
+	if transport, ok := p.transports[proxy]; ok {
+		return transport, nil
+	}
+	transport, err := newTransport(p.http, proxy)
+	if err != nil {
+		return nil, err
+	}
+	p.transports[proxy] = transport
+	return transport, nil
+}
+
+// closeTransports drops idle connections of all shared transports
+func (p *TelegramPlugin) closeTransports() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for proxy, transport := range p.transports {
+		transport.CloseIdleConnections()
+		delete(p.transports, proxy)
+	}
+}
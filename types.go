@@ -2,7 +2,9 @@ package main
 
 import (
 	"context"
+	"net/http"
 	"sync"
+	"time"
 
 	"github.com/dop251/goja"
 	"github.com/go-telegram/bot"
@@ -13,22 +15,76 @@ import (
 type TelegramPlugin struct {
 	initialized   bool
 	bots          map[string]*BotInstance
+	loops         map[*goja.Runtime]*jsLoop
 	mu            sync.RWMutex
 	storagePath   string
-	skipTLSVerify bool
+	http          httpConfig
+	transports    map[string]*http.Transport
+	queueSize     int
+	lanes         int
+	sessionStores map[string]SessionStoreFactory
+	errorHandlers map[*goja.Runtime]goja.Callable
 }
 
 // BotInstance represents a running Telegram bot
 type BotInstance struct {
-	bot            *bot.Bot
-	ctx            context.Context
-	cancel         context.CancelFunc
-	runtime        *goja.Runtime
-	handlers       map[string]goja.Callable
-	callbacks      map[string]goja.Callable
-	defaultHandler goja.Callable
-	storagePath    string
-	plugin         *TelegramPlugin
+	bot                *bot.Bot
+	id                 string
+	token              string
+	apiURL             string
+	localMode          bool
+	allowedUpdates     []string
+	httpClient         *http.Client
+	pollClient         *http.Client
+	pollTimeout        time.Duration
+	ctx                context.Context
+	cancel             context.CancelFunc
+	username           string
+	runtime            *goja.Runtime
+	loop               *jsLoop
+	lanes              *updateLanes
+	tracker            *updateTracker
+	handlers           *router
+	callbacks          *router
+	inlineQueries      *router
+	shippingQueries    *router
+	preCheckoutQueries *router
+	events             map[string]goja.Callable
+	scenes             *sceneManager
+	sessions           SessionStore
+	sessionConfig      *sessionConfig
+	broadcasts         map[string]*broadcastJob
+	broadcastsMu       sync.Mutex
+	defaultHandler     goja.Callable
+	errorHandler       goja.Callable
+	middlewares        []goja.Callable
+	storagePath        string
+	plugin             *TelegramPlugin
+	webhook            *http.Server
+	webhookMu          sync.Mutex
+	webhookStopped     bool
+	// ready is closed once the script that started the bot has returned
+	ready chan struct{}
+}
+
+// botOptions holds the options passed to startBot
+type botOptions struct {
+	mode        string
+	webhookURL  string
+	secretToken string
+	listen      string
+	path        string
+	session     *sessionConfig
+	dedupWindow int
+	rateLimit   floodLimits
+	apiURL      string
+	localMode   bool
+	// allowedUpdates is nil when it is computed from the handlers
+	allowedUpdates []string
+
+	proxy          string
+	requestTimeout time.Duration
+	pollTimeout    time.Duration
 }
 
 // UpdateContext provides context for handler callbacks
@@ -36,4 +92,12 @@ type UpdateContext struct {
 	instance *BotInstance
 	update   *models.Update
 	runtime  *goja.Runtime
+	match    []string
+	params   map[string]string
+	command  *commandInfo
+	scene    *sceneState
+
+	session         map[string]interface{}
+	sessionID       string
+	sessionSnapshot []byte
 }
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/dop251/goja"
+	"github.com/go-telegram/bot/models"
+)
+
+// updateTypes lists every update type, in the order of models.Update
+var updateTypes = []string{
+	"message",
+	"edited_message",
+	"channel_post",
+	"edited_channel_post",
+	"business_connection",
+	"business_message",
+	"edited_business_message",
+	"deleted_business_messages",
+	"message_reaction",
+	"message_reaction_count",
+	"inline_query",
+	"chosen_inline_result",
+	"callback_query",
+	"shipping_query",
+	"pre_checkout_query",
+	"purchased_paid_media",
+	"poll",
+	"poll_answer",
+	"my_chat_member",
+	"chat_member",
+	"chat_join_request",
+	"chat_boost",
+	"removed_chat_boost",
+}
+
+// optInUpdateTypes are only sent by Telegram when allowed_updates lists them
+var optInUpdateTypes = map[string]bool{
+	"chat_member":            true,
+	"message_reaction":       true,
+	"message_reaction_count": true,
+}
+
+// routedUpdateTypes are matched against the patterns of the handle* methods
+// and fall back to the default handler
+var routedUpdateTypes = []string{
+	"message",
+	"callback_query",
+	"inline_query",
+	"shipping_query",
+	"pre_checkout_query",
+}
+
+func isUpdateType(name string) bool {
+	for _, t := range updateTypes {
+		if t == name {
+			return true
+		}
+	}
+	return false
+}
+
+// updateType returns the Telegram name of an update's type
+func updateType(u *models.Update) string {
+	switch {
+	case u.Message != nil:
+		return "message"
+	case u.EditedMessage != nil:
+		return "edited_message"
+	case u.ChannelPost != nil:
+		return "channel_post"
+	case u.EditedChannelPost != nil:
+		return "edited_channel_post"
+	case u.BusinessConnection != nil:
+		return "business_connection"
+	case u.BusinessMessage != nil:
+		return "business_message"
+	case u.EditedBusinessMessage != nil:
+		return "edited_business_message"
+	case u.DeletedBusinessMessages != nil:
+		return "deleted_business_messages"
+	case u.MessageReaction != nil:
+		return "message_reaction"
+	case u.MessageReactionCount != nil:
+		return "message_reaction_count"
+	case u.InlineQuery != nil:
+		return "inline_query"
+	case u.ChosenInlineResult != nil:
+		return "chosen_inline_result"
+	case u.CallbackQuery != nil:
+		return "callback_query"
+	case u.ShippingQuery != nil:
+		return "shipping_query"
+	case u.PreCheckoutQuery != nil:
+		return "pre_checkout_query"
+	case u.PurchasedPaidMedia != nil:
+		return "purchased_paid_media"
+	case u.Poll != nil:
+		return "poll"
+	case u.PollAnswer != nil:
+		return "poll_answer"
+	case u.MyChatMember != nil:
+		return "my_chat_member"
+	case u.ChatMember != nil:
+		return "chat_member"
+	case u.ChatJoinRequest != nil:
+		return "chat_join_request"
+	case u.ChatBoost != nil:
+		return "chat_boost"
+	case u.RemovedChatBoost != nil:
+		return "removed_chat_boost"
+	}
+	return ""
+}
+
+// createOn registers a handler for a whole update type, e.g. "poll_answer".
+// For message, callback_query, inline_query, shipping_query and
+// pre_checkout_query it receives the updates no pattern matched.
+func (instance *BotInstance) createOn() func(string, goja.Callable) error {
+	return func(name string, handler goja.Callable) error {
+		if !isUpdateType(name) {
+			return fmt.Errorf("unknown update type %q", name)
+		}
+		instance.events[name] = handler
+		return nil
+	}
+}
+
+// parseAllowedUpdates reads the allowedUpdates option: a list of update
+// types, or nil to compute the list from the registered handlers
+func parseAllowedUpdates(value interface{}) ([]string, error) {
+	if value == nil {
+		return nil, nil
+	}
+	list, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("allowedUpdates must be an array of update types")
+	}
+	allowed := make([]string, 0, len(list))
+	for _, item := range list {
+		name, _ := item.(string)
+		if !isUpdateType(name) {
+			return nil, fmt.Errorf("unknown update type %q in allowedUpdates", fmt.Sprint(item))
+		}
+		allowed = append(allowed, name)
+	}
+	return allowed, nil
+}
+
+// handledUpdateTypes returns the update types the registered handlers can
+// receive, used as allowed_updates when the option is not set. Middlewares
+// see every update, so with middlewares the types Telegram sends by default
+// are kept as well. Must run on the event loop.
+func (instance *BotInstance) handledUpdateTypes() []string {
+	wanted := make(map[string]bool)
+	if len(instance.middlewares) > 0 {
+		for _, t := range updateTypes {
+			if !optInUpdateTypes[t] {
+				wanted[t] = true
+			}
+		}
+	}
+	if len(instance.handlers.routes) > 0 {
+		wanted["message"] = true
+	}
+	if len(instance.callbacks.routes) > 0 {
+		wanted["callback_query"] = true
+	}
+	if len(instance.inlineQueries.routes) > 0 {
+		wanted["inline_query"] = true
+	}
+	if len(instance.shippingQueries.routes) > 0 {
+		wanted["shipping_query"] = true
+	}
+	if len(instance.preCheckoutQueries.routes) > 0 {
+		wanted["pre_checkout_query"] = true
+	}
+	if instance.defaultHandler != nil {
+		for _, t := range routedUpdateTypes {
+			wanted[t] = true
+		}
+	}
+	if len(instance.scenes.scenes) > 0 {
+		wanted["message"] = true
+		wanted["callback_query"] = true
+	}
+	for t := range instance.events {
+		wanted[t] = true
+	}
+
+	allowed := make([]string, 0, len(wanted))
+	for _, t := range updateTypes {
+		if wanted[t] {
+			allowed = append(allowed, t)
+		}
+	}
+	return allowed
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/go-telegram/bot"
+)
+
+const (
+	defaultWebhookListen = ":8443"
+	defaultWebhookPath   = "/"
+	webhookStopTimeout   = 10 * time.Second
+)
+
+// startWebhook registers the webhook with Telegram and starts serving updates.
+// When cfg.webhookURL is empty setWebhook is skipped, which allows feeding
+// the endpoint by hand (e.g. from a local HTTP client in tests).
+func (instance *BotInstance) startWebhook(cfg botOptions) error {
+	listener, err := net.Listen("tcp", cfg.listen)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", cfg.listen, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(cfg.path, instance.webhookHandler(cfg.secretToken))
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	// stop may run concurrently (e.g. stopBot while the setup is pending)
+	instance.webhookMu.Lock()
+	if instance.webhookStopped {
+		instance.webhookMu.Unlock()
+		listener.Close()
+		return errors.New("bot stopped while starting")
+	}
+	instance.webhook = server
+	instance.webhookMu.Unlock()
+
+	go func() {
+		err := server.Serve(listener)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Printf("[ERROR] Webhook server stopped: %v\n", err)
+		}
+	}()
+
+	if cfg.webhookURL != "" {
+		_, err := instance.bot.SetWebhook(instance.ctx, &bot.SetWebhookParams{
+			URL:            cfg.webhookURL,
+			SecretToken:    cfg.secretToken,
+			AllowedUpdates: instance.allowedUpdates,
+		})
+		if err != nil {
+			// Nothing to delete at Telegram, just close the server unless
+			// stop already took it
+			instance.webhookMu.Lock()
+			owned := instance.webhook == server
+			if owned {
+				instance.webhook = nil
+			}
+			instance.webhookMu.Unlock()
+			if owned {
+				server.Close()
+			}
+			return fmt.Errorf("failed to set webhook: %w", newTelegramError(err))
+		}
+	}
+
+	go instance.bot.StartWebhook(instance.ctx)
+
+	return nil
+}
+
+// webhookHandler verifies the X-Telegram-Bot-Api-Secret-Token header before
+// passing the request to the library's webhook handler
+func (instance *BotInstance) webhookHandler(secretToken string) http.Handler {
+	handler := instance.bot.WebhookHandler()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if secretToken != "" {
+			got := r.Header.Get("X-Telegram-Bot-Api-Secret-Token")
+			if subtle.ConstantTimeCompare([]byte(got), []byte(secretToken)) != 1 {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+		}
+		handler(w, r)
+	})
+}
+
+// stopWebhook removes the webhook from Telegram and shuts the server down.
+// Once called, startWebhook fails; safe to call more than once.
+func (instance *BotInstance) stopWebhook() {
+	instance.webhookMu.Lock()
+	instance.webhookStopped = true
+	server := instance.webhook
+	instance.webhook = nil
+	instance.webhookMu.Unlock()
+	if server == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), webhookStopTimeout)
+	defer cancel()
+
+	if _, err := instance.bot.DeleteWebhook(ctx, &bot.DeleteWebhookParams{}); err != nil {
+		fmt.Printf("[ERROR] Failed to delete webhook: %v\n", newTelegramError(err))
+	}
+	if err := server.Shutdown(ctx); err != nil {
+		fmt.Printf("[ERROR] Failed to stop webhook server: %v\n", err)
+	}
+}
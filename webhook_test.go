@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+)
+
+func newWebhookTestServer(t *testing.T, secretToken string) (*httptest.Server, <-chan *models.Update) {
+	t.Helper()
+	updates := make(chan *models.Update, 1)
+	b, err := bot.New("123:test",
+		bot.WithSkipGetMe(),
+		bot.WithDefaultHandler(func(_ context.Context, _ *bot.Bot, update *models.Update) {
+			updates <- update
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go b.StartWebhook(ctx)
+
+	instance := &BotInstance{bot: b}
+	server := httptest.NewServer(instance.webhookHandler(secretToken))
+	t.Cleanup(server.Close)
+	return server, updates
+}
+
+func postUpdate(t *testing.T, url, secretToken string) int {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(`{"update_id": 42, "message": {"message_id": 1, "date": 0, "chat": {"id": 7, "type": "private"}, "text": "hi"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if secretToken != "" {
+		req.Header.Set("X-Telegram-Bot-Api-Secret-Token", secretToken)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestWebhookDeliversUpdateWithValidSecret(t *testing.T) {
+	server, updates := newWebhookTestServer(t, "s3cret")
+
+	if status := postUpdate(t, server.URL, "s3cret"); status != http.StatusOK {
+		t.Fatalf("status %d, want 200", status)
+	}
+	select {
+	case update := <-updates:
+		if update.ID != 42 || update.Message == nil || update.Message.Text != "hi" {
+			t.Fatalf("unexpected update %+v", update)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("update did not reach the handler")
+	}
+}
+
+func TestWebhookRejectsWrongSecret(t *testing.T) {
+	server, updates := newWebhookTestServer(t, "s3cret")
+
+	for _, secret := range []string{"wrong", ""} {
+		if status := postUpdate(t, server.URL, secret); status != http.StatusUnauthorized {
+			t.Fatalf("secret %q: status %d, want 401", secret, status)
+		}
+	}
+	select {
+	case update := <-updates:
+		t.Fatalf("update %d reached the handler", update.ID)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestWebhookRejectsNonPost(t *testing.T) {
+	server, _ := newWebhookTestServer(t, "s3cret")
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("status %d, want 405", resp.StatusCode)
+	}
+}
+
+func TestStartWebhookAfterStopFails(t *testing.T) {
+	b, err := bot.New("123:test", bot.WithSkipGetMe())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	instance := &BotInstance{bot: b, ctx: ctx, cancel: cancel}
+
+	instance.stopWebhook()
+	if err := instance.startWebhook(botOptions{listen: "127.0.0.1:0", path: "/"}); err == nil {
+		t.Fatal("webhook started on a stopped bot")
+	}
+	if instance.webhook != nil {
+		t.Fatal("stopped bot kept a webhook server")
+	}
+}